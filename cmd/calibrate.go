@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+
+	"github.com/lukjok/gipcfuzz/config"
+	"github.com/lukjok/gipcfuzz/loop"
+	"github.com/lukjok/gipcfuzz/models"
+	"github.com/pterm/pterm"
+)
+
+// runCalibration proposes the handlers of the stripped target from the coverage of every method
+func runCalibration(cfgPath, module string, runs int, dryRun bool) error {
+	settings := config.ParseConfigurationFile(cfgPath)
+
+	// Statistics are not shown, so nobody reads the UI channel
+	ctxData := models.ContextData{
+		Settings:   settings,
+		UIDataChan: make(chan *models.UIData, 1),
+	}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), "data", ctxData))
+	defer cancel()
+
+	looper := loop.NewLoop(ctx)
+	results, err := looper.Calibrate(module, runs)
+	if err != nil {
+		return err
+	}
+
+	handlers := make([]config.Handler, 0, len(results))
+	for _, result := range results {
+		if result.Handler == nil {
+			pterm.Error.Printfln("%s has no unique functions (%d unique blocks in %d runs)", result.Method, result.UniqueBlocks, result.Runs)
+			continue
+		}
+		handlers = append(handlers, *result.Handler)
+		pterm.Success.Printfln("%s -> %s (%d unique functions, %d unique blocks in %d runs)",
+			result.Method, result.Handler.HandlerName, len(result.UniqueFunctions), result.UniqueBlocks, result.Runs)
+	}
+
+	pterm.Info.Printfln("Proposed handlers for %d of %d methods", len(handlers), len(results))
+	if dryRun {
+		return nil
+	}
+	return writeHandlers(cfgPath, settings.Handlers, handlers)
+}
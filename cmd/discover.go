@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/lukjok/gipcfuzz/communication"
+	"github.com/lukjok/gipcfuzz/config"
+	"github.com/lukjok/gipcfuzz/symbol"
+	"github.com/lukjok/gipcfuzz/util"
+	"github.com/pkg/errors"
+	"github.com/pterm/pterm"
+)
+
+// runDiscoverHandlers matches the methods from the proto files with the functions of the module and writes
+// the found handlers to the configuration file. Handlers of the methods which were not matched are kept.
+func runDiscoverHandlers(cfgPath, modulePath string, dryRun bool) error {
+	settings := config.ParseConfigurationFile(cfgPath)
+	if len(modulePath) == 0 {
+		modulePath = settings.PathToExecutable
+	}
+
+	methods, err := listMethods(settings)
+	if err != nil {
+		return err
+	}
+	symbolizer, err := symbol.Open(modulePath)
+	if err != nil {
+		return err
+	}
+
+	handlers := make([]config.Handler, 0, len(methods))
+	found := make(map[string]bool, len(methods))
+	for _, match := range symbolizer.MatchMethods(methods) {
+		switch {
+		case match.Match != nil:
+			found[match.Method] = true
+			handlers = append(handlers, config.Handler{
+				Method:      match.Method,
+				Module:      filepath.Base(modulePath),
+				HandlerName: handlerName(symbolizer, *match.Match),
+				ModulePath:  modulePath,
+			})
+			pterm.Success.Printfln("%s -> %s (0x%x)", match.Method, match.Match.Name, match.Match.Offset)
+		case match.Ambiguous():
+			pterm.Warning.Printfln("%s matches several functions:", match.Method)
+			for _, candidate := range match.Candidates {
+				pterm.Printfln("    %s (0x%x)", candidate.Name, candidate.Offset)
+			}
+		default:
+			pterm.Error.Printfln("%s has no matching function", match.Method)
+		}
+	}
+
+	pterm.Info.Printfln("Found handlers for %d of %d methods", len(found), len(methods))
+	if dryRun {
+		return nil
+	}
+	return writeHandlers(cfgPath, settings.Handlers, handlers)
+}
+
+// writeHandlers saves the found handlers. Manually written handlers are kept for the methods which need a review.
+func writeHandlers(cfgPath string, existing, found []config.Handler) error {
+	foundMethods := make(map[string]bool, len(found))
+	for _, handler := range found {
+		foundMethods[handler.Method] = true
+	}
+	handlers := append([]config.Handler{}, found...)
+	for _, handler := range existing {
+		if !foundMethods[handler.Method] {
+			handlers = append(handlers, handler)
+		}
+	}
+
+	if err := config.WriteHandlers(cfgPath, handlers); err != nil {
+		return err
+	}
+	pterm.Info.Printfln("Handlers were written to %s", cfgPath)
+	return nil
+}
+
+// handlerName prefers the function name, so the handler stays valid after the target is rebuilt.
+// Offset is used when the name resolves to several functions (e.g. overloads).
+func handlerName(symbolizer *symbol.Symbolizer, fn symbol.Function) string {
+	if offset, err := symbolizer.ResolveName(fn.Name); err == nil && offset == fn.Offset {
+		return fn.Name
+	}
+	return fmt.Sprintf("0x%x", fn.Offset)
+}
+
+func listMethods(settings config.Configuration) ([]string, error) {
+	protoFiles := util.GetFileFullPathInDirectory(settings.ProtoFilesPath, []string{"Includes"})
+	source, err := communication.DescriptorSourceFromProtoFiles(settings.ProtoFilesIncludePath, protoFiles...)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Failed to process proto source files")
+	}
+
+	services, err := source.ListServices()
+	if err != nil {
+		return nil, err
+	}
+	methods := make([]string, 0, len(services)*4)
+	for _, svcName := range services {
+		dsc, err := source.FindSymbol(svcName)
+		if err != nil {
+			continue
+		}
+		if svc, ok := dsc.(*desc.ServiceDescriptor); ok {
+			for _, method := range svc.GetMethods() {
+				methods = append(methods, fmt.Sprintf("%s/%s", svc.GetFullyQualifiedName(), method.GetName()))
+			}
+		}
+	}
+	return methods, nil
+}
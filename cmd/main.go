@@ -38,6 +38,74 @@ func main() {
 				Usage:   "Path to the configuration file",
 			},
 		},
+		Commands: []*cli.Command{
+			{
+				Name:  "proxy",
+				Usage: "forward the traffic to the target and record every call as a seed",
+				Action: func(c *cli.Context) error {
+					// Fuzzing statistics are not used while proxying
+					ticker.Stop()
+					done <- true
+					area.Stop()
+					return runProxy(c.String("cfg"))
+				},
+			},
+			{
+				Name:  "discover-handlers",
+				Usage: "match the RPC methods with the functions of the target and write the handlers to the configuration",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "module",
+						Usage: "Path to the module with the handlers, the fuzzed executable is used by default",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Only report the matches without changing the configuration",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					ticker.Stop()
+					done <- true
+					area.Stop()
+					return runDiscoverHandlers(c.String("cfg"), c.String("module"), c.Bool("dry-run"))
+				},
+			},
+			{
+				Name:  "calibrate",
+				Usage: "find the handlers of a stripped target by comparing the coverage of every method",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "module",
+						Usage: "Name of the module with the handlers, the fuzzed executable is used by default",
+					},
+					&cli.IntFlag{
+						Name:  "runs",
+						Value: 3,
+						Usage: "Number of times the seeds of every method are sent",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Only report the proposed handlers without changing the configuration",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					ticker.Stop()
+					done <- true
+					area.Stop()
+					return runCalibration(c.String("cfg"), c.String("module"), c.Int("runs"), c.Bool("dry-run"))
+				},
+			},
+			{
+				Name:  "replay",
+				Usage: "send the captured session to the target and compare the responses with the captured ones",
+				Action: func(c *cli.Context) error {
+					ticker.Stop()
+					done <- true
+					area.Stop()
+					return runReplay(c.String("cfg"))
+				},
+			},
+		},
 		Action: func(c *cli.Context) error {
 			cfgPath := c.String("cfg")
 			if len(cfgPath) != 0 {
@@ -135,6 +203,8 @@ func updateUi(area *pterm.AreaPrinter, data models.UIData) {
 		{Level: 0, Text: pterm.Gray("Total paths: ") + pterm.White(data.TotalPaths)},
 		{Level: 0, Text: pterm.Gray("Unique crashes: ") + pterm.White(data.UniqCrash)},
 		{Level: 0, Text: pterm.Gray("Unique hangs: ") + pterm.White(data.UniqHangs)},
+		{Level: 0, Text: pterm.Gray("Total crashes / hangs: ") + pterm.White(fmt.Sprintf("%d / %d", data.TotalCrashes, data.TotalHangs))},
+		{Level: 0, Text: pterm.Gray("Unique / total findings: ") + pterm.White(fmt.Sprintf("%d / %d", data.UniqFindings, data.TotalFindings))},
 	}).Srender()
 	progress, _ := pterm.DefaultBulletList.WithItems([]pterm.BulletListItem{
 		{Level: 0, Text: pterm.Gray("Total executions: ") + pterm.White(data.TotalExec)},
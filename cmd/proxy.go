@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+
+	"github.com/lukjok/gipcfuzz/config"
+	"github.com/lukjok/gipcfuzz/proxy"
+	"github.com/lukjok/gipcfuzz/util"
+	"github.com/pterm/pterm"
+)
+
+// runProxy forwards the traffic to the target and records the seeds until interrupted
+func runProxy(cfgPath string) error {
+	settings := config.ParseConfigurationFile(cfgPath)
+
+	logger := util.NewLogger("log.txt")
+	prx, err := proxy.NewProxy(settings, logger)
+	if err != nil {
+		return err
+	}
+
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, os.Interrupt)
+	defer signal.Stop(signalChan)
+	go func() {
+		<-signalChan
+		prx.Stop()
+	}()
+
+	pterm.Info.Printfln("Proxying %s to %s, seeds are saved to %s",
+		settings.Proxy.ListenAddress, util.GetTargetEndpoint(settings), prx.Corpus.Dir)
+	return prx.Serve()
+}
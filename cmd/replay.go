@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+
+	"github.com/lukjok/gipcfuzz/config"
+	"github.com/lukjok/gipcfuzz/loop"
+	"github.com/lukjok/gipcfuzz/models"
+	"github.com/pkg/errors"
+	"github.com/pterm/pterm"
+)
+
+// runReplay sends the captured session to the target and fails if any call does not behave as in the capture
+func runReplay(cfgPath string) error {
+	settings := config.ParseConfigurationFile(cfgPath)
+
+	// Statistics are not shown, so nobody reads the UI channel
+	ctxData := models.ContextData{
+		Settings:   settings,
+		UIDataChan: make(chan *models.UIData, 1),
+	}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), "data", ctxData))
+	defer cancel()
+
+	looper := loop.NewLoop(ctx)
+	results, err := looper.Replay()
+	if err != nil {
+		return err
+	}
+
+	failed, matched := 0, 0
+	for _, result := range results {
+		switch {
+		case result.Failed():
+			failed++
+			pterm.Error.Printfln("#%d %s: %s - %s", result.Index, result.Method, result.Outcome, result.Description)
+		case result.Outcome == loop.ReplayMatch:
+			matched++
+		}
+	}
+
+	pterm.Info.Printfln("Replayed %d calls: %d matched, %d failed, %d had no captured response",
+		len(results), matched, failed, len(results)-matched-failed)
+	if failed > 0 {
+		return errors.Errorf("%d of %d replayed calls did not behave as in the capture", failed, len(results))
+	}
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"github.com/jhump/protoreflect/grpcreflect"
+	"github.com/lukjok/gipcfuzz/envelope"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
@@ -51,7 +52,19 @@ func SendRequestWithMessage(request GIPCRequest) (proto.Message, error) {
 	serverName = &emptyStringHelper
 	authority = &emptyStringHelper
 
-	target = request.Endpoint
+	network, address, err := ParseTarget(request.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	target = address
+	isUnixSocket = func() bool {
+		return network == "unix"
+	}
+	if isUnixSocket() {
+		// Socket paths are not valid authorities, so use the same one as grpc-go does
+		localAuthorityHelper := string("localhost")
+		authority = &localAuthorityHelper
+	}
 	symbol = request.Path
 
 	var cc *grpc.ClientConn
@@ -66,6 +79,9 @@ func SendRequestWithMessage(request GIPCRequest) (proto.Message, error) {
 		if *connectTimeout > 0 {
 			dialTime = time.Duration(*connectTimeout * float64(time.Second))
 		}
+		if request.DialTimeout > 0 {
+			dialTime = request.DialTimeout
+		}
 		ctx, cancel := context.WithTimeout(ctx, dialTime)
 		defer cancel()
 		var opts []grpc.DialOption
@@ -137,6 +153,18 @@ func SendRequestWithMessage(request GIPCRequest) (proto.Message, error) {
 
 	descSource = fileSource
 
+	// Deadline covers only the call, the connection has its own timeout
+	callCtx := ctx
+	if request.Timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, request.Timeout)
+		defer cancel()
+	}
+
+	if len(request.Protocol) > 0 && request.Protocol != envelope.ProtocolGRPC {
+		return sendWebRequest(callCtx, request, network, address, descSource)
+	}
+
 	reset := func() {
 		if refClient != nil {
 			refClient.Reset()
@@ -174,7 +202,7 @@ func SendRequestWithMessage(request GIPCRequest) (proto.Message, error) {
 		return nil, connError
 	}
 
-	err = InvokeRPC(ctx, descSource, cc, symbol, append(addlHeaders, rpcHeaders...), h, rf.Next)
+	err = InvokeRPC(callCtx, descSource, cc, symbol, append(addlHeaders, rpcHeaders...), h, rf.Next)
 	if err != nil {
 		if errStatus, ok := status.FromError(err); ok {
 			h.Status = errStatus
@@ -0,0 +1,50 @@
+package communication
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// ProbeHealth calls grpc.health.v1.Health/Check and succeeds only if the service is serving.
+// Empty service name checks the health of the whole server.
+func ProbeHealth(target string, service string, useTLS bool, timeout time.Duration) error {
+	network, address, err := ParseTarget(target)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var opts []grpc.DialOption
+	if network == "unix" {
+		// Socket paths are not valid authorities
+		opts = append(opts, grpc.WithAuthority("localhost"))
+	}
+	var creds credentials.TransportCredentials
+	if useTLS {
+		if creds, err = ClientTransportCredentials(true, "", "", ""); err != nil {
+			return err
+		}
+	}
+
+	cc, err := BlockingDial(ctx, network, address, creds, opts...)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+
+	response, err := grpc_health_v1.NewHealthClient(cc).Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: service})
+	if err != nil {
+		return err
+	}
+	if response.GetStatus() != grpc_health_v1.HealthCheckResponse_SERVING {
+		return errors.Errorf("Service is %s", response.GetStatus())
+	}
+	return nil
+}
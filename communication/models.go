@@ -1,9 +1,17 @@
 package communication
 
+import "time"
+
 type GIPCRequest struct {
 	Endpoint          string
 	Path              string
 	Data              []byte
 	ProtoFiles        []string
 	ProtoIncludesPath []string
+	Protocol          string
+	UseHTTP2          bool
+	// Timeout is the deadline of the call, zero means no deadline
+	Timeout time.Duration
+	// DialTimeout limits the connection, zero means the default of 10 seconds
+	DialTimeout time.Duration
 }
@@ -0,0 +1,167 @@
+package communication
+
+import (
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RawCodec passes the serialized messages as they are, so no generated types are needed
+// to serve or forward the calls
+type RawCodec struct{}
+
+func (RawCodec) Marshal(v interface{}) ([]byte, error) {
+	buf, ok := v.(*[]byte)
+	if !ok {
+		return nil, errors.Errorf("Unexpected message type %T for the raw codec", v)
+	}
+	return *buf, nil
+}
+
+func (RawCodec) Unmarshal(data []byte, v interface{}) error {
+	buf, ok := v.(*[]byte)
+	if !ok {
+		return errors.Errorf("Unexpected message type %T for the raw codec", v)
+	}
+	*buf = append((*buf)[:0], data...)
+	return nil
+}
+
+func (RawCodec) Name() string {
+	// Has to match the default codec name, otherwise content subtype negotiation fails
+	return "proto"
+}
+
+// FakeServerResponder returns the serialized response for the serialized request of the method
+type FakeServerResponder func(method *desc.MethodDescriptor, request []byte) ([]byte, error)
+
+// FakeServer is a gRPC server which serves all services from the proto descriptors
+// and replies with the responses provided by the responder
+type FakeServer struct {
+	server    *grpc.Server
+	listener  net.Listener
+	responder FakeServerResponder
+}
+
+// NewFakeServer registers the services from the given proto files and starts listening on the target
+func NewFakeServer(target string, protoFiles []string, protoIncludesPath []string, responder FakeServerResponder) (*FakeServer, error) {
+	source, err := DescriptorSourceFromProtoFiles(protoIncludesPath, protoFiles...)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Failed to process proto source files")
+	}
+
+	network, address, err := ParseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	if network == "unix" && !strings.HasPrefix(address, "@") {
+		// Remove the stale socket which is left after the previous run
+		os.Remove(address)
+	}
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "Failed to listen on %s", target)
+	}
+
+	fs := &FakeServer{
+		server:    grpc.NewServer(grpc.ForceServerCodec(RawCodec{})),
+		listener:  listener,
+		responder: responder,
+	}
+
+	services, err := source.ListServices()
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	for _, svcName := range services {
+		dsc, err := source.FindSymbol(svcName)
+		if err != nil {
+			continue
+		}
+		if svc, ok := dsc.(*desc.ServiceDescriptor); ok {
+			fs.server.RegisterService(fs.newServiceDesc(svc), struct{}{})
+		}
+	}
+
+	return fs, nil
+}
+
+// Serve blocks until the server is stopped
+func (fs *FakeServer) Serve() error {
+	return fs.server.Serve(fs.listener)
+}
+
+func (fs *FakeServer) Stop() {
+	fs.server.Stop()
+}
+
+func (fs *FakeServer) newServiceDesc(svc *desc.ServiceDescriptor) *grpc.ServiceDesc {
+	serviceDesc := &grpc.ServiceDesc{
+		ServiceName: svc.GetFullyQualifiedName(),
+		HandlerType: (*interface{})(nil),
+		Streams:     make([]grpc.StreamDesc, 0, len(svc.GetMethods())),
+		Metadata:    svc.GetFile().GetName(),
+	}
+	for _, mtd := range svc.GetMethods() {
+		// Every method is registered as a stream, since the wire format of the unary calls is the same
+		serviceDesc.Streams = append(serviceDesc.Streams, grpc.StreamDesc{
+			StreamName:    mtd.GetName(),
+			Handler:       fs.newMethodHandler(mtd),
+			ServerStreams: true,
+			ClientStreams: true,
+		})
+	}
+	return serviceDesc
+}
+
+func (fs *FakeServer) newMethodHandler(mtd *desc.MethodDescriptor) grpc.StreamHandler {
+	return func(_ interface{}, stream grpc.ServerStream) error {
+		var lastRequest []byte
+		for {
+			var request []byte
+			if err := stream.RecvMsg(&request); err != nil {
+				if err != io.EOF {
+					return err
+				}
+				// Client streaming calls get a single response after all requests are received
+				if mtd.IsClientStreaming() && !mtd.IsServerStreaming() {
+					return fs.respond(mtd, stream, lastRequest)
+				}
+				return nil
+			}
+
+			if mtd.IsClientStreaming() && !mtd.IsServerStreaming() {
+				lastRequest = request
+				continue
+			}
+
+			if err := fs.respond(mtd, stream, request); err != nil {
+				return err
+			}
+
+			if !mtd.IsClientStreaming() {
+				return nil
+			}
+		}
+	}
+}
+
+func (fs *FakeServer) respond(mtd *desc.MethodDescriptor, stream grpc.ServerStream, request []byte) error {
+	response, err := fs.responder(mtd, request)
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+	return stream.SendMsg(&response)
+}
@@ -0,0 +1,59 @@
+package communication
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	unixTargetPrefix         = "unix://"
+	unixAbstractTargetPrefix = "unix-abstract:"
+)
+
+// ParseTarget splits the configured target into the network and the address
+// that can be passed to the dialer. Supported formats are "unix:///path/to/socket",
+// "unix-abstract:name" and plain "host:port".
+func ParseTarget(target string) (string, string, error) {
+	if len(target) == 0 {
+		return "", "", errors.New("Target address was empty")
+	}
+
+	if strings.HasPrefix(target, unixTargetPrefix) {
+		path := strings.TrimPrefix(target, unixTargetPrefix)
+		if len(path) == 0 {
+			return "", "", errors.Errorf("Unix socket path is missing in the target: %s", target)
+		}
+		return "unix", path, nil
+	}
+
+	if strings.HasPrefix(target, unixAbstractTargetPrefix) {
+		name := strings.TrimPrefix(target, unixAbstractTargetPrefix)
+		if len(name) == 0 {
+			return "", "", errors.Errorf("Abstract socket name is missing in the target: %s", target)
+		}
+		// Go dials the abstract namespace when the name starts with '@'
+		return "unix", "@" + name, nil
+	}
+
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		return "", "", errors.Errorf("Failed to parse target %s: %s", target, err)
+	}
+	return "tcp", target, nil
+}
+
+// ProbeTarget checks if the target accepts new connections
+func ProbeTarget(target string, timeout time.Duration) error {
+	network, address, err := ParseTarget(target)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout(network, address, timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
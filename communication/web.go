@@ -0,0 +1,316 @@
+package communication
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/lukjok/gipcfuzz/envelope"
+	"github.com/pkg/errors"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const connectProtocolVersion = "1"
+
+var connectCodes = map[string]codes.Code{
+	"canceled":            codes.Canceled,
+	"unknown":             codes.Unknown,
+	"invalid_argument":    codes.InvalidArgument,
+	"deadline_exceeded":   codes.DeadlineExceeded,
+	"not_found":           codes.NotFound,
+	"already_exists":      codes.AlreadyExists,
+	"permission_denied":   codes.PermissionDenied,
+	"resource_exhausted":  codes.ResourceExhausted,
+	"failed_precondition": codes.FailedPrecondition,
+	"aborted":             codes.Aborted,
+	"out_of_range":        codes.OutOfRange,
+	"unimplemented":       codes.Unimplemented,
+	"internal":            codes.Internal,
+	"unavailable":         codes.Unavailable,
+	"data_loss":           codes.DataLoss,
+	"unauthenticated":     codes.Unauthenticated,
+}
+
+type connectError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type connectEndStream struct {
+	Error *connectError `json:"error"`
+}
+
+// sendWebRequest delivers the request using gRPC-Web or Connect protocols over HTTP/1.1 or HTTP/2
+func sendWebRequest(ctx context.Context, request GIPCRequest, network, address string, source DescriptorSource) (proto.Message, error) {
+	mtd, err := findMethodDescriptor(source, request.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	isStreaming := mtd.IsClientStreaming() || mtd.IsServerStreaming()
+	contentType, body, err := encodeWebRequest(request.Protocol, isStreaming, request.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	host := address
+	if network == "unix" {
+		host = "localhost"
+	}
+	endpoint := url.URL{Scheme: "http", Host: host, Path: "/" + strings.TrimPrefix(request.Path, "/")}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	httpReq.Header.Set("User-Agent", "gipcfuzz")
+	switch request.Protocol {
+	case envelope.ProtocolGRPCWeb, envelope.ProtocolGRPCWebText:
+		httpReq.Header.Set("Accept", contentType)
+		httpReq.Header.Set("X-Grpc-Web", "1")
+	case envelope.ProtocolConnect:
+		httpReq.Header.Set("Connect-Protocol-Version", connectProtocolVersion)
+	}
+
+	client := &http.Client{Transport: newWebTransport(network, address, request.UseHTTP2)}
+	httpRes, err := client.Do(httpReq)
+	if err != nil {
+		return nil, unwrapWebError(err)
+	}
+	defer httpRes.Body.Close()
+
+	resBody, err := ioutil.ReadAll(httpRes.Body)
+	if err != nil {
+		return nil, unwrapWebError(err)
+	}
+
+	var msgs [][]byte
+	if request.Protocol == envelope.ProtocolConnect {
+		msgs, err = decodeConnectResponse(httpRes, resBody, isStreaming)
+	} else {
+		msgs, err = decodeGRPCWebResponse(httpRes, resBody, request.Protocol == envelope.ProtocolGRPCWebText)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(msgs) == 0 {
+		return nil, status.Error(codes.Internal, "Response did not contain any messages")
+	}
+
+	// Same as with gRPC, only the last received response is returned
+	response := dynamic.NewMessage(mtd.GetOutputType())
+	if err := response.Unmarshal(msgs[len(msgs)-1]); err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to unmarshal the response: %s", err)
+	}
+	return response, nil
+}
+
+func encodeWebRequest(protocol string, isStreaming bool, data []byte) (string, []byte, error) {
+	switch protocol {
+	case envelope.ProtocolGRPCWeb:
+		return envelope.ContentTypeGRPCWeb, envelope.Encode(0, data), nil
+	case envelope.ProtocolGRPCWebText:
+		return envelope.ContentTypeGRPCWebText, []byte(base64.StdEncoding.EncodeToString(envelope.Encode(0, data))), nil
+	case envelope.ProtocolConnect:
+		if isStreaming {
+			return envelope.ContentTypeConnectStreaming, envelope.Encode(0, data), nil
+		}
+		return envelope.ContentTypeConnectUnary, data, nil
+	default:
+		return "", nil, errors.Errorf("Unknown protocol: %s", protocol)
+	}
+}
+
+func newWebTransport(network, address string, useHTTP2 bool) http.RoundTripper {
+	dial := func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, address)
+	}
+	if useHTTP2 {
+		// Plaintext HTTP/2 (h2c) without the upgrade, same as the native gRPC
+		return &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(_, _ string, _ *tls.Config) (net.Conn, error) {
+				return dial(context.Background(), "", "")
+			},
+		}
+	}
+	return &http.Transport{DialContext: dial, DisableKeepAlives: true}
+}
+
+func decodeGRPCWebResponse(res *http.Response, body []byte, isText bool) ([][]byte, error) {
+	// Trailers-only responses carry the status in the headers
+	if code := res.Header.Get("Grpc-Status"); len(code) > 0 {
+		if err := grpcStatusError(code, res.Header.Get("Grpc-Message")); err != nil {
+			return nil, err
+		}
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, status.Errorf(httpStatusToCode(res.StatusCode), "Unexpected HTTP status: %s", res.Status)
+	}
+
+	if isText {
+		decoded, err := envelope.DecodeText(body)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		body = decoded
+	}
+
+	frames, err := envelope.Decode(body)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	msgs := make([][]byte, 0, 1)
+	for _, frame := range frames {
+		if frame.Flags&envelope.FlagGRPCWebTrailers != 0 {
+			trailers, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(frame.Data))).ReadMIMEHeader()
+			if err != nil && len(trailers) == 0 {
+				return nil, status.Errorf(codes.Internal, "Failed to parse gRPC-Web trailers: %s", err)
+			}
+			if err := grpcStatusError(trailers.Get("Grpc-Status"), trailers.Get("Grpc-Message")); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if frame.IsCompressed() {
+			return nil, status.Error(codes.Internal, "Compressed responses are not supported")
+		}
+		msgs = append(msgs, frame.Data)
+	}
+	return msgs, nil
+}
+
+func decodeConnectResponse(res *http.Response, body []byte, isStreaming bool) ([][]byte, error) {
+	if !isStreaming {
+		if res.StatusCode != http.StatusOK {
+			cErr := connectError{}
+			if err := json.Unmarshal(body, &cErr); err != nil || len(cErr.Code) == 0 {
+				return nil, status.Errorf(httpStatusToCode(res.StatusCode), "Unexpected HTTP status: %s", res.Status)
+			}
+			return nil, connectStatusError(&cErr)
+		}
+		return [][]byte{body}, nil
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, status.Errorf(httpStatusToCode(res.StatusCode), "Unexpected HTTP status: %s", res.Status)
+	}
+
+	frames, err := envelope.Decode(body)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	msgs := make([][]byte, 0, 1)
+	for _, frame := range frames {
+		if frame.Flags&envelope.FlagConnectEndStream != 0 {
+			end := connectEndStream{}
+			if err := json.Unmarshal(frame.Data, &end); err != nil {
+				return nil, status.Errorf(codes.Internal, "Failed to parse Connect end of stream message: %s", err)
+			}
+			if end.Error != nil {
+				return nil, connectStatusError(end.Error)
+			}
+			continue
+		}
+		if frame.IsCompressed() {
+			return nil, status.Error(codes.Internal, "Compressed responses are not supported")
+		}
+		msgs = append(msgs, frame.Data)
+	}
+	return msgs, nil
+}
+
+func grpcStatusError(code string, message string) error {
+	if len(code) == 0 {
+		return nil
+	}
+	parsedCode, err := strconv.Atoi(strings.TrimSpace(code))
+	if err != nil {
+		return status.Errorf(codes.Internal, "Invalid grpc-status value: %s", code)
+	}
+	if codes.Code(parsedCode) == codes.OK {
+		return nil
+	}
+	if decoded, err := url.PathUnescape(message); err == nil {
+		message = decoded
+	}
+	return status.Error(codes.Code(parsedCode), message)
+}
+
+func connectStatusError(cErr *connectError) error {
+	code, ok := connectCodes[cErr.Code]
+	if !ok {
+		code = codes.Unknown
+	}
+	return status.Error(code, cErr.Message)
+}
+
+func httpStatusToCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.Internal
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.Unimplemented
+	case http.StatusRequestTimeout:
+		return codes.DeadlineExceeded
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	default:
+		return codes.Unknown
+	}
+}
+
+// unwrapWebError returns the underlying network error, so it is treated the same as with gRPC
+func unwrapWebError(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		if opErr, ok := urlErr.Err.(*net.OpError); ok {
+			return opErr
+		}
+		return urlErr.Err
+	}
+	return err
+}
+
+func findMethodDescriptor(source DescriptorSource, methodName string) (*desc.MethodDescriptor, error) {
+	svc, mth := parseSymbol(methodName)
+	if svc == "" || mth == "" {
+		return nil, fmt.Errorf("given method name %q is not in expected format: 'service/method' or 'service.method'", methodName)
+	}
+	dsc, err := source.FindSymbol(svc)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "Failed to find service %q", svc)
+	}
+	sd, ok := dsc.(*desc.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("target server does not expose service %q", svc)
+	}
+	mtd := sd.FindMethodByName(mth)
+	if mtd == nil {
+		return nil, fmt.Errorf("service %q does not include a method named %q", svc, mth)
+	}
+	return mtd, nil
+}
@@ -1,9 +1,13 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"io/ioutil"
 	"log"
+
+	"github.com/lukjok/gipcfuzz/envelope"
+	"github.com/pkg/errors"
 )
 
 func ParseConfigurationFile(path string) Configuration {
@@ -22,6 +26,120 @@ func ParseConfigurationFile(path string) Configuration {
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := unmarshalledConf.Validate(); err != nil {
+		log.Fatal(err)
+	}
 
 	return unmarshalledConf
 }
+
+// Validate checks the settings which are not checked by the JSON decoding
+func (c Configuration) Validate() error {
+	if err := validateProtocol(c.Protocol); err != nil {
+		return err
+	}
+	return validateProtocol(c.Differential.Protocol)
+}
+
+func validateProtocol(protocol string) error {
+	switch protocol {
+	case "", envelope.ProtocolGRPC, envelope.ProtocolGRPCWeb, envelope.ProtocolGRPCWebText, envelope.ProtocolConnect:
+		return nil
+	}
+	return errors.Errorf("Unknown protocol %q, expected one of %s, %s, %s or %s", protocol,
+		envelope.ProtocolGRPC, envelope.ProtocolGRPCWeb, envelope.ProtocolGRPCWebText, envelope.ProtocolConnect)
+}
+
+// WriteHandlers replaces the handlers section of the configuration file. Only the value of the
+// handlers key is rewritten, other settings keep their order, formatting and unknown keys.
+func WriteHandlers(path string, handlers []Handler) error {
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Errorf("Failed to read the configuration file: %s", err)
+	}
+
+	encHandlers, err := json.MarshalIndent(handlers, "    ", "    ")
+	if err != nil {
+		return err
+	}
+	out, err := spliceHandlers(dat, encHandlers)
+	if err != nil {
+		return errors.Errorf("Failed to parse the configuration file: %s", err)
+	}
+	return ioutil.WriteFile(path, out, 0644)
+}
+
+// spliceHandlers puts the value into the place of the top level handlers key, or appends the key if it is missing
+func spliceHandlers(dat []byte, value []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(dat))
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, errors.New("configuration is not a JSON object")
+	}
+
+	keys := 0
+	lastEnd := int(dec.InputOffset())
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		keys++
+		lastEnd = int(dec.InputOffset())
+		if tok == "handlers" {
+			start := lastEnd - len(raw)
+			return concat(dat[:start], value, dat[lastEnd:]), nil
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	entry := []byte("\n    \"handlers\": ")
+	if keys > 0 {
+		entry = append([]byte(","), entry...)
+	}
+	entry = append(entry, value...)
+	if keys == 0 {
+		entry = append(entry, '\n')
+	}
+	return concat(dat[:lastEnd], entry, dat[lastEnd:]), nil
+}
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out
+}
+
+// DifferentialTarget returns the configuration of the second target in the differential mode
+func (c Configuration) DifferentialTarget() Configuration {
+	diff := c.Differential
+	second := c
+	if len(diff.Target) > 0 || len(diff.Host) > 0 || diff.Port != 0 {
+		second.Target = diff.Target
+		if len(diff.Host) > 0 {
+			second.Host = diff.Host
+		}
+		if diff.Port != 0 {
+			second.Port = diff.Port
+		}
+	}
+	if len(diff.Protocol) > 0 {
+		second.Protocol = diff.Protocol
+	}
+	if len(diff.Transport) > 0 {
+		second.Transport = diff.Transport
+	}
+	if len(diff.RawResponseType) > 0 {
+		second.RawResponseType = diff.RawResponseType
+	}
+	return second
+}
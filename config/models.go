@@ -1,27 +1,152 @@
 package config
 
 type Configuration struct {
-	PathToExecutable           string    `json:"pathToExecutable"`
-	ExecutableArguments        []string  `json:"executableArgs"`
-	OutputPath                 string    `json:"outputPath"`
-	DumpExecutablePath         string    `json:"dumpExecutablePath"`
-	PerformMemoryDump          bool      `json:"performMemoryDump"`
-	Handlers                   []Handler `json:"handlers"`
-	Host                       string    `json:"host"`
-	Port                       int32     `json:"port"`
-	SSL                        bool      `json:"ssl"`
-	DryRun                     bool      `json:"performDryRun"`
-	DoSingleFieldMutation      bool      `json:"singleFieldMutation"`
-	DoDependencyUnawareSending bool      `json:"dependencyUnawareSending"`
-	UseInstrumentation         bool      `json:"useInstrumentation"`
-	ProtoFilesPath             string    `json:"protoFilesPath"`
-	ProtoFilesIncludePath      []string  `json:"protoFilesIncludePath"`
-	PcapFilePath               string    `json:"pcapFilePath"`
-	MaxMsgSize                 int32     `json:"maxMsgSize"`
+	PathToExecutable           string              `json:"pathToExecutable"`
+	ExecutableArguments        []string            `json:"executableArgs"`
+	Launch                     LaunchConfig        `json:"launch"`
+	OutputPath                 string              `json:"outputPath"`
+	DumpExecutablePath         string              `json:"dumpExecutablePath"`
+	PerformMemoryDump          bool                `json:"performMemoryDump"`
+	Handlers                   []Handler           `json:"handlers"`
+	Host                       string              `json:"host"`
+	Port                       int32               `json:"port"`
+	Target                     string              `json:"target"`
+	SSL                        bool                `json:"ssl"`
+	Protocol                   string              `json:"protocol"`
+	UseHTTP2                   bool                `json:"http2"`
+	Transport                  string              `json:"transport"`
+	RawRequestType             string              `json:"rawRequestType"`
+	RawResponseType            string              `json:"rawResponseType"`
+	DryRun                     bool                `json:"performDryRun"`
+	FuzzClient                 bool                `json:"fuzzClient"`
+	DoSingleFieldMutation      bool                `json:"singleFieldMutation"`
+	DoDependencyUnawareSending bool                `json:"dependencyUnawareSending"`
+	UseInstrumentation         bool                `json:"useInstrumentation"`
+	UsePtrace                  bool                `json:"usePtrace"`
+	ProtoFilesPath             string              `json:"protoFilesPath"`
+	ProtoFilesIncludePath      []string            `json:"protoFilesIncludePath"`
+	PcapFilePath               string              `json:"pcapFilePath"`
+	CorpusPath                 string              `json:"corpusPath"`
+	Proxy                      ProxyConfig         `json:"proxy"`
+	Readiness                  ReadinessConfig     `json:"readiness"`
+	External                   ExternalConfig      `json:"external"`
+	ResourceOracle             ResourceOracle      `json:"resourceOracle"`
+	LatencyOracle              LatencyOracle       `json:"latencyOracle"`
+	StatusOracle               StatusOracle        `json:"statusOracle"`
+	Invariants                 map[string][]string `json:"invariants"`
+	Differential               DifferentialConfig  `json:"differential"`
+	Replay                     ReplayConfig        `json:"replay"`
+	InjectionOracle            InjectionOracle     `json:"injectionOracle"`
+	Scheduling                 string              `json:"scheduling"`
+	MaxMsgSize                 int32               `json:"maxMsgSize"`
+	RequestTimeout             int                 `json:"requestTimeout"`
+	TimeoutMultiplier          float64             `json:"timeoutMultiplier"`
+}
+
+// LaunchConfig is applied when the target is started. Wall-clock limit is in milliseconds.
+type LaunchConfig struct {
+	Environment      map[string]string `json:"env"`
+	WorkingDirectory string            `json:"workingDirectory"`
+	StdinFile        string            `json:"stdinFile"`
+	Limits           ResourceLimits    `json:"limits"`
+	WallClockLimit   int               `json:"wallClockLimit"`
+}
+
+// ResourceLimits of the target process, zero means the limit of the fuzzer is inherited. CPU time is in seconds.
+type ResourceLimits struct {
+	AddressSpace uint64 `json:"addressSpace"`
+	CPUTime      uint64 `json:"cpuTime"`
+	OpenFiles    uint64 `json:"openFiles"`
 }
 
 type Handler struct {
 	Method      string `json:"method"`
 	Module      string `json:"module"`
 	HandlerName string `json:"handler"`
+	ModulePath  string `json:"modulePath"`
+}
+
+type ProxyConfig struct {
+	ListenAddress       string  `json:"listen"`
+	MutationProbability float64 `json:"mutationProbability"`
+	MutateRequests      bool    `json:"mutateRequests"`
+	MutateResponses     bool    `json:"mutateResponses"`
+}
+
+// ReadinessConfig tells how to check that the started target accepts the calls. Timeouts are in milliseconds.
+type ReadinessConfig struct {
+	Mode          string `json:"mode"`
+	Service       string `json:"service"`
+	Method        string `json:"method"`
+	Request       string `json:"request"`
+	StartTimeout  int    `json:"startTimeout"`
+	ProbeTimeout  int    `json:"probeTimeout"`
+	ProbeInterval int    `json:"probeInterval"`
+}
+
+// ExternalConfig describes the target which is started outside the fuzzer. It is found by the PID, the process
+// name or the listening socket, and restarted with the user command. Timeout is in milliseconds.
+type ExternalConfig struct {
+	Enabled        bool     `json:"enabled"`
+	Pid            int      `json:"pid"`
+	ProcessName    string   `json:"processName"`
+	Socket         string   `json:"socket"`
+	RestartCommand []string `json:"restartCommand"`
+	RestartTimeout int      `json:"restartTimeout"`
+}
+
+// ResourceOracle tells which growth of the target resources is reported. Zero thresholds use the defaults.
+// RSS is in bytes and CPU time is in milliseconds.
+type ResourceOracle struct {
+	Enabled          bool   `json:"enabled"`
+	RSSJump          uint64 `json:"rssJump"`
+	CPUJump          int    `json:"cpuJump"`
+	ThreadJump       int    `json:"threadJump"`
+	OpenFileJump     int    `json:"openFileJump"`
+	RSSLeakCandidate uint64 `json:"rssLeakCandidate"`
+	RepeatRuns       int    `json:"repeatRuns"`
+	LeakWindow       int    `json:"leakWindow"`
+}
+
+// LatencyOracle tells which calls are reported as slow. Minimal latency is in milliseconds.
+type LatencyOracle struct {
+	Enabled       bool    `json:"enabled"`
+	OutlierFactor float64 `json:"outlierFactor"`
+	MinSamples    int     `json:"minSamples"`
+	MinLatency    int     `json:"minLatency"`
+}
+
+// DifferentialConfig describes the second target which gets the same inputs as the fuzzed one. Its connection
+// settings which are not set are taken from the fuzzed target. Ignored fields are the proto field names or paths.
+type DifferentialConfig struct {
+	Enabled         bool     `json:"enabled"`
+	Host            string   `json:"host"`
+	Port            int32    `json:"port"`
+	Target          string   `json:"target"`
+	Protocol        string   `json:"protocol"`
+	Transport       string   `json:"transport"`
+	RawResponseType string   `json:"rawResponseType"`
+	IgnoreFields    []string `json:"ignoreFields"`
+}
+
+// ReplayConfig tells which fields of the captured responses are not compared by the replay
+type ReplayConfig struct {
+	IgnoreFields []string `json:"ignoreFields"`
+}
+
+// InjectionOracle tells how often the canary payloads are put into the string fields. Canary files are kept in
+// a new temporary directory and the callbacks are received on a random local port by default.
+type InjectionOracle struct {
+	Enabled     bool    `json:"enabled"`
+	Probability float64 `json:"probability"`
+	Directory   string  `json:"directory"`
+	Listen      string  `json:"listen"`
+}
+
+// StatusOracle tells which status codes of the live target are reported. Methods override the codes for a method.
+type StatusOracle struct {
+	Enabled      bool                `json:"enabled"`
+	Codes        []string            `json:"codes"`
+	Methods      map[string][]string `json:"methods"`
+	LeakPatterns []string            `json:"leakPatterns"`
 }
@@ -0,0 +1,178 @@
+package envelope
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Protocols which can be used to deliver the messages to the fuzzed application
+const (
+	ProtocolGRPC        = "grpc"
+	ProtocolGRPCWeb     = "grpc-web"
+	ProtocolGRPCWebText = "grpc-web-text"
+	ProtocolConnect     = "connect"
+)
+
+const (
+	ContentTypeGRPC             = "application/grpc"
+	ContentTypeGRPCWeb          = "application/grpc-web+proto"
+	ContentTypeGRPCWebText      = "application/grpc-web-text+proto"
+	ContentTypeConnectUnary     = "application/proto"
+	ContentTypeConnectStreaming = "application/connect+proto"
+)
+
+// Envelope flags used by the gRPC, gRPC-Web and Connect protocols
+const (
+	FlagCompressed       byte = 0x01
+	FlagConnectEndStream byte = 0x02
+	FlagGRPCWebTrailers  byte = 0x80
+)
+
+const headerLength = 5
+
+// Frame is a single length prefixed message of the stream
+type Frame struct {
+	Flags byte
+	Data  []byte
+}
+
+// IsTrailer returns true if the frame contains the gRPC-Web trailers or the Connect end of stream message
+func (f Frame) IsTrailer() bool {
+	return f.Flags&FlagGRPCWebTrailers != 0 || f.Flags&FlagConnectEndStream != 0
+}
+
+// IsCompressed returns true if the frame data is compressed
+func (f Frame) IsCompressed() bool {
+	return f.Flags&FlagCompressed != 0
+}
+
+// Encode wraps the data into the length prefixed envelope
+func Encode(flags byte, data []byte) []byte {
+	buf := make([]byte, headerLength+len(data))
+	buf[0] = flags
+	binary.BigEndian.PutUint32(buf[1:headerLength], uint32(len(data)))
+	copy(buf[headerLength:], data)
+	return buf
+}
+
+// Decode splits the body into the length prefixed frames. Incomplete last frame is returned as an error.
+func Decode(body []byte) ([]Frame, error) {
+	frames := make([]Frame, 0, 1)
+	for len(body) > 0 {
+		if len(body) < headerLength {
+			return frames, errors.New("Envelope header is truncated!")
+		}
+		length := binary.BigEndian.Uint32(body[1:headerLength])
+		if uint64(len(body)-headerLength) < uint64(length) {
+			return frames, errors.New("Envelope data is truncated!")
+		}
+		frames = append(frames, Frame{
+			Flags: body[0],
+			Data:  body[headerLength : headerLength+int(length)],
+		})
+		body = body[headerLength+int(length):]
+	}
+	return frames, nil
+}
+
+// DecodeText decodes the base64 body of the gRPC-Web text protocol. Each message of the stream
+// can be encoded separately, so the body may contain multiple padded base64 chunks.
+func DecodeText(body []byte) ([]byte, error) {
+	body = bytes.TrimSpace(body)
+	decoded := make([]byte, 0, base64.StdEncoding.DecodedLen(len(body)))
+	for len(body) > 0 {
+		// Chunk ends after the padding or at the end of the body
+		end := len(body)
+		if idx := bytes.IndexByte(body, '='); idx >= 0 {
+			end = idx
+			for end < len(body) && body[end] == '=' {
+				end++
+			}
+		}
+		chunk := make([]byte, base64.StdEncoding.DecodedLen(end))
+		n, err := base64.StdEncoding.Decode(chunk, body[:end])
+		if err != nil {
+			return nil, errors.WithMessage(err, "Failed to decode gRPC-Web text body")
+		}
+		decoded = append(decoded, chunk[:n]...)
+		body = body[end:]
+	}
+	return decoded, nil
+}
+
+// MediaType returns the lowercase content type without parameters
+func MediaType(contentType string) string {
+	if idx := strings.IndexByte(contentType, ';'); idx >= 0 {
+		contentType = contentType[:idx]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// IsWebContentType returns true if the content type belongs to gRPC-Web or Connect protocols
+func IsWebContentType(contentType string) bool {
+	mediaType := MediaType(contentType)
+	return strings.HasPrefix(mediaType, "application/grpc-web") ||
+		strings.HasPrefix(mediaType, "application/connect") ||
+		mediaType == ContentTypeConnectUnary
+}
+
+// DecodeMessages extracts the protobuf messages from the HTTP body according to its content type.
+// Trailers, end of stream messages and compressed messages are skipped.
+func DecodeMessages(contentType string, body []byte) ([][]byte, error) {
+	mediaType := MediaType(contentType)
+	switch {
+	case mediaType == ContentTypeConnectUnary:
+		return [][]byte{body}, nil
+	case strings.HasPrefix(mediaType, "application/grpc-web-text"):
+		decoded, err := DecodeText(body)
+		if err != nil {
+			return nil, err
+		}
+		body = decoded
+	case strings.HasSuffix(mediaType, "+json") || mediaType == "application/json":
+		return nil, errors.Errorf("JSON encoded messages are not supported: %s", contentType)
+	case strings.HasPrefix(mediaType, "application/grpc-web"), strings.HasPrefix(mediaType, "application/connect"), strings.HasPrefix(mediaType, ContentTypeGRPC):
+	default:
+		return nil, errors.Errorf("Unknown content type: %s", contentType)
+	}
+
+	frames, err := Decode(body)
+	msgs := make([][]byte, 0, len(frames))
+	for _, frame := range frames {
+		if frame.IsTrailer() || frame.IsCompressed() {
+			continue
+		}
+		msgs = append(msgs, frame.Data)
+	}
+	if len(msgs) == 0 && err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// TrailerStatus returns the grpc-status of the gRPC-Web trailers at the end of the body
+func TrailerStatus(contentType string, body []byte) (string, bool) {
+	if strings.HasPrefix(MediaType(contentType), "application/grpc-web-text") {
+		decoded, err := DecodeText(body)
+		if err != nil {
+			return "", false
+		}
+		body = decoded
+	}
+	frames, _ := Decode(body)
+	for _, frame := range frames {
+		if frame.Flags&FlagGRPCWebTrailers == 0 {
+			continue
+		}
+		for _, line := range strings.Split(string(frame.Data), "\r\n") {
+			if idx := strings.IndexByte(line, ':'); idx >= 0 && strings.EqualFold(strings.TrimSpace(line[:idx]), "grpc-status") {
+				return strings.TrimSpace(line[idx+1:]), true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,20 @@
+//go:build linux
+// +build linux
+
+package events
+
+// Events is a no-op on Linux, since there is no system event log for the crashes.
+// The crash details are taken from the exit status of the supervised process instead.
+type Events struct{}
+
+func (e *Events) GetEventData() []string {
+	return []string{}
+}
+
+func (e *Events) StopCapture() {}
+
+func (e *Events) StartCapture() {}
+
+func (e *Events) NewEventManager(searchQuery string) error {
+	return nil
+}
@@ -0,0 +1,270 @@
+package loop
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/lukjok/gipcfuzz/config"
+	"github.com/lukjok/gipcfuzz/models"
+	"github.com/lukjok/gipcfuzz/packet"
+	"github.com/lukjok/gipcfuzz/trace"
+	"github.com/lukjok/gipcfuzz/watcher"
+	"github.com/pkg/errors"
+)
+
+// CalibrationResult is the handler proposed for the method by comparing its coverage with the other methods
+type CalibrationResult struct {
+	Method  string
+	Handler *config.Handler
+	// Functions called only by this method, the best handler candidates first
+	UniqueFunctions []uint64
+	UniqueBlocks    int
+	Runs            int
+}
+
+type methodCoverage struct {
+	// Functions and blocks reached in every run, in the order of the first call
+	calls  []uint64
+	blocks map[uint64]bool
+	// Everything reached at least once, so the noise of the other methods is not counted as unique
+	seenCalls  map[uint64]bool
+	seenBlocks map[uint64]bool
+	// Calls between the functions in any run, the functions called from outside of the module are entered
+	callees map[uint64]map[uint64]bool
+	entered map[uint64]bool
+	runs    int
+}
+
+// Calibrate sends the seeds of every method several times while the whole module is traced. Functions
+// which are called only by a single method are the candidates for its handler. The candidate which
+// is called from the shared code and reaches the most of the other unique functions is proposed, so the
+// request deserializer or the response serializer is not mistaken for the handler.
+func (l *Loop) Calibrate(module string, runs int) ([]CalibrationResult, error) {
+	loopData := l.Context.Value("data").(models.ContextData)
+	procName := filepath.Base(loopData.Settings.PathToExecutable)
+	if len(module) == 0 {
+		module = procName
+	}
+	if runs < 1 {
+		runs = 1
+	}
+
+	seeds := make(map[string][]LoopMessage)
+	methods := make([]string, 0, 8)
+	l.prepareMessages(requestMessages(l.loadMessages()))
+	for _, msg := range l.Messages {
+		if _, ok := seeds[msg.Path]; !ok {
+			methods = append(methods, msg.Path)
+		}
+		seeds[msg.Path] = append(seeds[msg.Path], msg)
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("No seeds were found for the calibration!")
+	}
+
+	go l.handleProcessStartWithoutReporting()
+	l.waitForTarget()
+	if err := l.Trace.StartModule(procName, module); err != nil {
+		return nil, err
+	}
+	defer l.Trace.Stop()
+	defer l.Trace.StopModule()
+
+	coverage := make(map[string]*methodCoverage, len(methods))
+	for _, method := range methods {
+		l.Logger.LogInfo(fmt.Sprintf("Calibrating %s", method))
+		cov, err := l.calibrateMethod(seeds[method], runs, procName, module)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "Calibration of %s was aborted", method)
+		}
+		coverage[method] = cov
+	}
+
+	results := make([]CalibrationResult, 0, len(methods))
+	for _, method := range methods {
+		cov := coverage[method]
+		result := CalibrationResult{Method: method, Runs: cov.runs}
+		for _, call := range cov.calls {
+			if !seenByOthers(coverage, method, func(other *methodCoverage) bool { return other.seenCalls[call] }) {
+				result.UniqueFunctions = append(result.UniqueFunctions, call)
+			}
+		}
+		for block := range cov.blocks {
+			if !seenByOthers(coverage, method, func(other *methodCoverage) bool { return other.seenBlocks[block] }) {
+				result.UniqueBlocks++
+			}
+		}
+		result.UniqueFunctions = cov.rankCandidates(result.UniqueFunctions)
+		if len(result.UniqueFunctions) > 0 {
+			result.Handler = &config.Handler{
+				Method:      method,
+				Module:      module,
+				HandlerName: fmt.Sprintf("0x%x", result.UniqueFunctions[0]),
+			}
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Method < results[j].Method })
+	return results, nil
+}
+
+// calibrateMethod collects the coverage of the method. Target which has crashed is started again and
+// the module coverage is started in it, so every run is traced.
+func (l *Loop) calibrateMethod(seeds []LoopMessage, runs int, procName, module string) (*methodCoverage, error) {
+	cov := &methodCoverage{
+		seenCalls:  make(map[uint64]bool),
+		seenBlocks: make(map[uint64]bool),
+		callees:    make(map[uint64]map[uint64]bool),
+		entered:    make(map[uint64]bool),
+	}
+	for i := 0; i < runs; i++ {
+		seed := seeds[i%len(seeds)]
+		if err := l.Trace.ClearModuleCoverage(); err != nil {
+			l.Logger.LogError(err.Error())
+			continue
+		}
+		if _, err := l.runIterationWithData(seed.Path, seed.Message); err != nil {
+			// Error responses still reach the handler, only the unreachable target is a failed run
+			if !watcher.IsProcessRunning(l.Context) {
+				l.Logger.LogError(err.Error())
+				go l.handleProcessStartWithoutReporting()
+				l.waitForTarget()
+				// Script of the crashed process is gone with its session
+				l.Trace.Unload()
+				if err := l.Trace.StartModule(procName, module); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+		runCov, err := l.Trace.GetModuleCoverage()
+		if err != nil {
+			l.Logger.LogError(err.Error())
+			continue
+		}
+		cov.add(runCov)
+	}
+	return cov, nil
+}
+
+func (c *methodCoverage) add(runCov *trace.ModuleCoverage) {
+	runCalls := make(map[uint64]bool, len(runCov.Calls))
+	for _, call := range runCov.Calls {
+		runCalls[call] = true
+		c.seenCalls[call] = true
+	}
+	runBlocks := make(map[uint64]bool, len(runCov.Blocks))
+	for _, block := range runCov.Blocks {
+		runBlocks[block] = true
+		c.seenBlocks[block] = true
+	}
+
+	for _, edge := range runCov.Edges {
+		if !edge.HasCaller {
+			c.entered[edge.Callee] = true
+			continue
+		}
+		if c.callees[edge.Caller] == nil {
+			c.callees[edge.Caller] = make(map[uint64]bool)
+		}
+		c.callees[edge.Caller][edge.Callee] = true
+	}
+
+	if c.runs == 0 {
+		c.calls = append(c.calls, runCov.Calls...)
+		c.blocks = runBlocks
+	} else {
+		calls := c.calls[:0]
+		for _, call := range c.calls {
+			if runCalls[call] {
+				calls = append(calls, call)
+			}
+		}
+		c.calls = calls
+		for block := range c.blocks {
+			if !runBlocks[block] {
+				delete(c.blocks, block)
+			}
+		}
+	}
+	c.runs++
+}
+
+// rankCandidates orders the unique functions by how likely they are the handler. Handler is called by the
+// shared code of the RPC framework and calls most of the method specific code, while the deserializer
+// and the serializer are called the same way but reach only the code of their message. Functions which
+// are called only from the other unique functions go last, in the order of the first call.
+func (c *methodCoverage) rankCandidates(unique []uint64) []uint64 {
+	isUnique := make(map[uint64]bool, len(unique))
+	for _, fn := range unique {
+		isUnique[fn] = true
+	}
+	isEntry := make(map[uint64]bool, len(unique))
+	for fn := range c.entered {
+		isEntry[fn] = isUnique[fn]
+	}
+	for caller, callees := range c.callees {
+		if isUnique[caller] {
+			continue
+		}
+		for callee := range callees {
+			if isUnique[callee] {
+				isEntry[callee] = true
+			}
+		}
+	}
+
+	reach := make(map[uint64]int, len(unique))
+	for _, fn := range unique {
+		if isEntry[fn] {
+			reach[fn] = c.reachableUnique(fn, isUnique)
+		}
+	}
+
+	ranked := append([]uint64(nil), unique...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if isEntry[ranked[i]] != isEntry[ranked[j]] {
+			return isEntry[ranked[i]]
+		}
+		return reach[ranked[i]] > reach[ranked[j]]
+	})
+	return ranked
+}
+
+// reachableUnique counts the unique functions called directly or indirectly from the function
+func (c *methodCoverage) reachableUnique(fn uint64, isUnique map[uint64]bool) int {
+	visited := map[uint64]bool{fn: true}
+	queue := []uint64{fn}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for callee := range c.callees[current] {
+			if isUnique[callee] && !visited[callee] {
+				visited[callee] = true
+				queue = append(queue, callee)
+			}
+		}
+	}
+	return len(visited) - 1
+}
+
+func seenByOthers(coverage map[string]*methodCoverage, method string, seen func(*methodCoverage) bool) bool {
+	for other, cov := range coverage {
+		if other != method && seen(cov) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestMessages keeps only the requests, since the responses cannot be sent to the target
+func requestMessages(msgs []packet.ProtoByteMsg) []packet.ProtoByteMsg {
+	requests := make([]packet.ProtoByteMsg, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg.Type == packet.Request {
+			requests = append(requests, msg)
+		}
+	}
+	return requests
+}
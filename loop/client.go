@@ -0,0 +1,184 @@
+package loop
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/lukjok/gipcfuzz/communication"
+	"github.com/lukjok/gipcfuzz/events"
+	"github.com/lukjok/gipcfuzz/models"
+	"github.com/lukjok/gipcfuzz/mutator"
+	"github.com/lukjok/gipcfuzz/packet"
+	"github.com/lukjok/gipcfuzz/trace"
+	"github.com/lukjok/gipcfuzz/util"
+	"github.com/lukjok/gipcfuzz/watcher"
+)
+
+// doClientFuzzing runs the fuzzer as a fake server and replies to the fuzzed client with
+// mutated responses. Captured responses are used as seeds and the client is restarted after every exit.
+func (l *Loop) doClientFuzzing(rSrc rand.Source) {
+	l.Logger.LogInfo("Starting client fuzzing!")
+	loopData := l.Context.Value("data").(models.ContextData)
+
+	messages := packet.GetParsedMessages(
+		loopData.Settings.PcapFilePath,
+		loopData.Settings.ProtoFilesPath,
+		loopData.Settings.ProtoFilesIncludePath)
+	l.prepareResponseSeeds(messages)
+
+	if len(l.Messages) == 0 {
+		l.Logger.LogError("No response messages were processed! Bailing out...")
+		os.Exit(1)
+	}
+
+	var mutStrategy mutator.MutationStrategy
+	if loopData.Settings.DoSingleFieldMutation {
+		mutStrategy = mutator.SingleField
+	} else {
+		mutStrategy = mutator.WholeMessage
+	}
+	mutMgr := new(mutator.MutatorManager)
+	mutMgr.New(new(mutator.DefaultDependencyUnawareMut), new(mutator.DefaultDependencyAwareMut), int(loopData.Settings.MaxMsgSize), rSrc, []string{}, mutStrategy)
+
+	protoFiles := util.GetFileFullPathInDirectory(loopData.Settings.ProtoFilesPath, []string{"Includes"})
+	server, err := communication.NewFakeServer(
+		util.GetTargetEndpoint(loopData.Settings),
+		protoFiles,
+		loopData.Settings.ProtoFilesIncludePath,
+		l.newClientResponder(mutMgr))
+	if err != nil {
+		l.Logger.LogError(err.Error())
+		os.Exit(1)
+	}
+	defer server.Stop()
+
+	go func() {
+		if err := server.Serve(); err != nil {
+			l.Logger.LogError(err.Error())
+		}
+	}()
+
+	if err := l.Events.NewEventManager(events.DefaultWindowsQuery); err != nil {
+		l.Logger.LogError(err.Error())
+	}
+	l.Events.StartCapture()
+
+	for {
+		select {
+		case <-l.Context.Done():
+			// Instrumentation is not used for the clients, so only the event capture is stopped
+			l.Events.StopCapture()
+			l.Logger.LogInfo("Ending client fuzzing!")
+			return
+		default:
+			l.Status.IterationNo += 1
+			// Returns after the client exits or crashes, then it is started again
+			l.handleProcessStart()
+			l.waitForProcessExit()
+			l.sendUIUpdate()
+		}
+	}
+}
+
+// prepareResponseSeeds keeps one captured response per method as a seed
+func (l *Loop) prepareResponseSeeds(msgs []packet.ProtoByteMsg) {
+	resMsgs := make([]packet.ProtoByteMsg, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg.Type == packet.Response && msg.Message != nil && msg.Descriptor != nil {
+			resMsgs = append(resMsgs, msg)
+		}
+	}
+
+	l.Messages = make([]LoopMessage, 0, 1)
+	for _, msg := range packet.DistinctMessages(resMsgs) {
+		msgBuf, _ := hex.DecodeString(*msg.Message)
+		l.Messages = append(l.Messages, LoopMessage{
+			Path:       msg.Path,
+			Message:    msgBuf,
+			Descriptor: msg.Descriptor,
+			Energy:     0,
+			Coverage:   make([]trace.CoverageBlock, 0, 1),
+		})
+	}
+}
+
+func (l *Loop) newClientResponder(mutMgr *mutator.MutatorManager) communication.FakeServerResponder {
+	var mu sync.Mutex
+	mutated := map[string]*dynamic.Message{}
+
+	return func(mtd *desc.MethodDescriptor, request []byte) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		path := fmt.Sprintf("%s/%s", mtd.GetService().GetFullyQualifiedName(), mtd.GetName())
+		var seed *LoopMessage
+		for i := 0; i < len(l.Messages); i++ {
+			if l.Messages[i].Path == path {
+				seed = &l.Messages[i]
+				break
+			}
+		}
+
+		// Methods without a captured response get an empty message which is still a valid response
+		if seed == nil {
+			return []byte{}, nil
+		}
+
+		// Same as with the servers, mutations are accumulated on top of the previous ones
+		message, ok := mutated[path]
+		if !ok {
+			message = dynamic.NewMessage(seed.Descriptor)
+			if err := message.Unmarshal(seed.Message); err != nil {
+				return nil, err
+			}
+			mutated[path] = message
+		}
+
+		msgBuf := append([]byte{}, seed.Message...)
+		if err := mutMgr.DoMutation(seed.Descriptor, message, &msgBuf); err != nil {
+			l.Logger.LogError(err.Error())
+			delete(mutated, path)
+			return seed.Message, nil
+		}
+
+		l.setServedMessage(&LoopMessage{
+			Path:       path,
+			Descriptor: seed.Descriptor,
+			Message:    append([]byte{}, msgBuf...),
+		})
+		return msgBuf, nil
+	}
+}
+
+// setServedMessage records the response sent to the client, so its crash is saved with the last served message
+func (l *Loop) setServedMessage(msg *LoopMessage) {
+	l.servedLock.Lock()
+	defer l.servedLock.Unlock()
+	l.CurrentMessage = msg
+	l.Status.TotalExec += 1
+}
+
+// currentSnapshot copies the current message, which the fake server may replace at any time
+func (l *Loop) currentSnapshot() *LoopMessage {
+	l.servedLock.Lock()
+	defer l.servedLock.Unlock()
+	return l.CurrentMessage.snapshot()
+}
+
+func (l *Loop) waitForProcessExit() {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+	for watcher.IsProcessRunning(l.Context) {
+		select {
+		case <-l.Context.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
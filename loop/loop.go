@@ -10,21 +10,27 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/jhump/protoreflect/dynamic"
-	"github.com/lukjok/gipcfuzz/communication"
 	"github.com/lukjok/gipcfuzz/config"
 	"github.com/lukjok/gipcfuzz/events"
 	"github.com/lukjok/gipcfuzz/memdump"
 	"github.com/lukjok/gipcfuzz/models"
 	"github.com/lukjok/gipcfuzz/mutator"
+	"github.com/lukjok/gipcfuzz/oracle"
 	"github.com/lukjok/gipcfuzz/output"
 	"github.com/lukjok/gipcfuzz/packet"
+	"github.com/lukjok/gipcfuzz/symbol"
 	"github.com/lukjok/gipcfuzz/trace"
+	"github.com/lukjok/gipcfuzz/transport"
+	"github.com/lukjok/gipcfuzz/triage"
 	"github.com/lukjok/gipcfuzz/util"
 	"github.com/lukjok/gipcfuzz/watcher"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/protobuf/runtime/protoiface"
 )
 
@@ -43,8 +49,27 @@ type Loop struct {
 	MsgDepMap      map[string]int
 	Output         *output.Filesystem
 	Events         *events.Events
-	MemDump        *memdump.MemoryDump
+	MemDump        memdump.MemoryDumpManager
 	Trace          *trace.Trace
+	Transport      transport.Transport
+	Symbols        *symbol.Cache
+	ResourceOracle *oracle.Resources
+	LatencyOracle  *oracle.Latency
+	StatusOracle   *oracle.Status
+	Invariants     *oracle.Invariants
+	Differential   *oracle.Differential
+	Injection      *oracle.Injection
+	// SecondTransport sends the inputs to the second target of the differential mode
+	SecondTransport transport.Transport
+	hangSignatures  map[string]bool
+	lastUsage       *watcher.ResourceUsage
+	lastUsagePid    int
+	lastLatency     time.Duration
+	lastLatencyObs  *oracle.LatencyObservation
+	// Slowest inputs of the perf scheduling, which are fuzzed again after the queue entry they came from
+	slowQueue []LoopMessage
+	// Guards the current message and the executions, which the fake server updates while the client is fuzzed
+	servedLock     sync.Mutex
 	Status         *LoopStatus
 	CurrentMessage *LoopMessage
 }
@@ -60,32 +85,77 @@ func NewLoop(ctx context.Context) *Loop {
 		logger.LogError("Failed to initialize tracing manager!")
 		os.Exit(1)
 	}
+	traceManager.ResolvePid = func() int { return watcher.GetProcessPid(ctx) }
 
+	msgTransport, err := transport.NewTransport(ctxData.Settings)
+	if err != nil {
+		logger.LogError(err.Error())
+		os.Exit(1)
+	}
+
+	l := &Loop{
+		Logger:    logger,
+		Context:   ctx,
+		Status:    &LoopStatus{},
+		Output:    output.NewFilesystem(ctxData.Settings.OutputPath),
+		Events:    &events.Events{},
+		Trace:     traceManager,
+		Transport: msgTransport,
+		Symbols:   symbol.NewCache(),
+	}
 	if ctxData.Settings.PerformMemoryDump {
-		return &Loop{
-			Logger:  logger,
-			Context: ctx,
-			Status:  &LoopStatus{},
-			Output:  output.NewFilesystem(ctxData.Settings.OutputPath),
-			Events:  &events.Events{},
-			Trace:   traceManager,
-			MemDump: memdump.NewMemoryDump(ctxData.Settings.PathToExecutable, ctxData.Settings.OutputPath, ctxData.Settings.DumpExecutablePath),
+		l.MemDump = memdump.NewMemoryDumpManager(ctxData.Settings.PathToExecutable, ctxData.Settings.Launch.WorkingDirectory,
+			ctxData.Settings.OutputPath, ctxData.Settings.DumpExecutablePath)
+	}
+	if ctxData.Settings.ResourceOracle.Enabled {
+		l.ResourceOracle = oracle.NewResources(ctxData.Settings.ResourceOracle)
+	}
+	if ctxData.Settings.LatencyOracle.Enabled || ctxData.Settings.Scheduling == SchedulingPerf {
+		l.LatencyOracle = oracle.NewLatency(ctxData.Settings.LatencyOracle)
+	}
+	if ctxData.Settings.StatusOracle.Enabled {
+		if l.StatusOracle, err = oracle.NewStatus(ctxData.Settings.StatusOracle); err != nil {
+			logger.LogError(err.Error())
+			os.Exit(1)
 		}
-	} else {
-		return &Loop{
-			Logger:  logger,
-			Context: ctx,
-			Status:  &LoopStatus{},
-			Output:  output.NewFilesystem(ctxData.Settings.OutputPath),
-			Trace:   traceManager,
-			Events:  &events.Events{},
+	}
+	if ctxData.Settings.Differential.Enabled {
+		if l.SecondTransport, err = transport.NewTransport(ctxData.Settings.DifferentialTarget()); err != nil {
+			logger.LogError(err.Error())
+			os.Exit(1)
+		}
+		l.Differential = oracle.NewDifferential(ctxData.Settings.Differential.IgnoreFields)
+	}
+	if ctxData.Settings.InjectionOracle.Enabled {
+		if l.Injection, err = oracle.NewInjection(ctxData.Settings.InjectionOracle); err != nil {
+			logger.LogError(err.Error())
+			os.Exit(1)
+		}
+		logger.LogInfo(fmt.Sprintf("Canary files are in %s, callbacks are received on %s", l.Injection.Directory, l.Injection.Address))
+		if !l.Injection.WatchesReads() {
+			logger.LogWarning("File reads cannot be watched, path traversal canaries are not injected")
+		}
+	}
+	if len(ctxData.Settings.Invariants) > 0 {
+		if l.Invariants, err = oracle.NewInvariants(ctxData.Settings.Invariants); err != nil {
+			logger.LogError(err.Error())
+			os.Exit(1)
 		}
 	}
+	return l
 }
 
 func (l *Loop) Run() {
 	rSrc := rand.NewSource(time.Hour.Nanoseconds())
 	loopData := l.Context.Value("data").(models.ContextData)
+	if loopData.Settings.UseInstrumentation {
+		l.resolveHandlers()
+	}
+	if loopData.Settings.FuzzClient {
+		l.doClientFuzzing(rSrc)
+		l.Logger.LogInfo("Ending the run!")
+		return
+	}
 	l.initializeLoop()
 	// // Initializing new trace manager since this helps to prevent Frida crashes
 	// tm, err := trace.NewTraceManager()
@@ -104,7 +174,9 @@ func (l *Loop) Run() {
 func (l *Loop) doDependencyAwareSending(rSrc rand.Source) {
 	l.Logger.LogInfo("Starting dependency aware sending!")
 	loopData := l.Context.Value("data").(models.ContextData)
-	for idx, mChain := range l.MessageChains {
+	// Chains are indexed, since the slow inputs are queued as new chains while the loop runs
+	for idx := 0; idx < len(l.MessageChains); idx++ {
+		mChain := l.MessageChains[idx]
 		select {
 		case <-l.Context.Done():
 			l.Stop()
@@ -134,14 +206,7 @@ func (l *Loop) doDependencyAwareSending(rSrc rand.Source) {
 				continue
 			}
 
-			ticker := time.NewTicker(1 * time.Second)
-			for range ticker.C {
-				if watcher.IsProcessRunning(l.Context) {
-					break
-				}
-			}
-			ticker.Stop()
-			ticker = nil
+			l.waitForTarget()
 
 			if len(mChain.Messages) > 1 {
 				// Send all messages in order before the last one
@@ -181,6 +246,7 @@ func (l *Loop) doDependencyAwareSending(rSrc rand.Source) {
 			}
 
 			var programCrashed bool = false
+			var slowest slowestInput
 
 			for i := l.CurrentMessage.Energy; i != 0; i-- {
 
@@ -195,8 +261,11 @@ func (l *Loop) doDependencyAwareSending(rSrc rand.Source) {
 					l.Logger.LogError(err.Error())
 					break
 				}
+				if l.Injection != nil {
+					l.injectCanary()
+				}
 
-				_, rErr := l.runIterationWithData(l.CurrentMessage.Path, l.CurrentMessage.Message)
+				response, rErr := l.runIterationWithData(l.CurrentMessage.Path, l.CurrentMessage.Message)
 
 				l.Status.MsgProg = 100 - float64((100*i)/l.CurrentMessage.Energy)
 				l.Status.TotalExec += 1
@@ -207,6 +276,10 @@ func (l *Loop) doDependencyAwareSending(rSrc rand.Source) {
 					l.handleIterationErr(rErr)
 					programCrashed = true
 				}
+				l.checkIteration(response, rErr)
+				if loopData.Settings.Scheduling == SchedulingPerf {
+					l.scheduleSlowest(&slowest, message)
+				}
 
 				if loopData.Settings.UseInstrumentation {
 					cov, err := l.Trace.GetCoverage()
@@ -222,6 +295,9 @@ func (l *Loop) doDependencyAwareSending(rSrc rand.Source) {
 
 				l.sendUIUpdate()
 			}
+			for _, slow := range l.takeSlowQueue() {
+				l.MessageChains = append(l.MessageChains, slowChain(mChain, slow))
+			}
 
 			if loopData.Settings.UseInstrumentation {
 				if err := l.Trace.Unload(); err != nil {
@@ -238,7 +314,9 @@ func (l *Loop) doDependencyAwareSending(rSrc rand.Source) {
 func (l *Loop) doDependencyUnawareSending(rSrc rand.Source) {
 	l.Logger.LogInfo("Starting dependency aware sending!")
 	loopData := l.Context.Value("data").(models.ContextData)
-	for idx, message := range l.Messages {
+	// Messages are indexed, since the new inputs are queued while the loop runs
+	for idx := 0; idx < len(l.Messages); idx++ {
+		message := l.Messages[idx]
 		select {
 		case <-l.Context.Done():
 			l.Stop()
@@ -266,14 +344,7 @@ func (l *Loop) doDependencyUnawareSending(rSrc rand.Source) {
 				continue
 			}
 
-			ticker := time.NewTicker(1 * time.Second)
-			for range ticker.C {
-				if watcher.IsProcessRunning(l.Context) {
-					break
-				}
-			}
-			ticker.Stop()
-			ticker = nil
+			l.waitForTarget()
 
 			if loopData.Settings.UseInstrumentation {
 				procName := filepath.Base(loopData.Settings.PathToExecutable)
@@ -291,6 +362,7 @@ func (l *Loop) doDependencyUnawareSending(rSrc rand.Source) {
 				}
 			}
 
+			var slowest slowestInput
 			for i := l.CurrentMessage.Energy; i != 0; i-- {
 				mutMgr := new(mutator.MutatorManager)
 				mutMgr.New(new(mutator.DefaultDependencyUnawareMut), new(mutator.DefaultDependencyAwareMut), int(loopData.Settings.MaxMsgSize), rSrc, []string{}, mutStrategy)
@@ -301,8 +373,11 @@ func (l *Loop) doDependencyUnawareSending(rSrc rand.Source) {
 					l.Logger.LogError(err.Error())
 					break
 				}
+				if l.Injection != nil {
+					l.injectCanary()
+				}
 
-				_, rErr := l.runIterationWithData(l.CurrentMessage.Path, l.CurrentMessage.Message)
+				response, rErr := l.runIterationWithData(l.CurrentMessage.Path, l.CurrentMessage.Message)
 
 				l.Status.MsgProg = 100 - float64((100*i)/l.CurrentMessage.Energy)
 				l.Status.TotalExec += 1
@@ -312,6 +387,10 @@ func (l *Loop) doDependencyUnawareSending(rSrc rand.Source) {
 					l.Logger.LogError(rErr.Error())
 					l.handleIterationErr(rErr)
 				}
+				l.checkIteration(response, rErr)
+				if loopData.Settings.Scheduling == SchedulingPerf {
+					l.scheduleSlowest(&slowest, message)
+				}
 
 				if loopData.Settings.UseInstrumentation {
 					cov, err := l.Trace.GetCoverage()
@@ -329,6 +408,7 @@ func (l *Loop) doDependencyUnawareSending(rSrc rand.Source) {
 				runtime.GC()
 				mutMgr = nil
 			}
+			l.Messages = append(l.Messages, l.takeSlowQueue()...)
 
 			if loopData.Settings.UseInstrumentation {
 				if err := l.Trace.Unload(); err != nil {
@@ -343,7 +423,12 @@ func (l *Loop) doDependencyUnawareSending(rSrc rand.Source) {
 
 func (l *Loop) sendUIUpdate() {
 	loopData := l.Context.Value("data").(models.ContextData)
-	loopData.UIDataChan <- &models.UIData{
+	l.servedLock.Lock()
+	currMsg := ""
+	if l.CurrentMessage != nil {
+		currMsg = l.CurrentMessage.Path
+	}
+	data := &models.UIData{
 		StartTime:           time.Time{},
 		LastCrashTime:       l.Status.LastCrashTime,
 		LastHangTime:        l.Status.LastHangTime,
@@ -352,11 +437,17 @@ func (l *Loop) sendUIUpdate() {
 		ExecSpd:             l.Status.TotalExec / 60,
 		UniqCrash:           l.Status.UniqueCrashCount,
 		UniqHangs:           l.Status.UniqueHangCount,
+		TotalCrashes:        l.Status.TotalCrashCount,
+		TotalHangs:          l.Status.TotalHangCount,
+		UniqFindings:        l.Status.UniqueFindingCount,
+		TotalFindings:       l.Status.TotalFindingCount,
 		TotalExec:           l.Status.TotalExec,
-		CurrMsg:             l.CurrentMessage.Path,
+		CurrMsg:             currMsg,
 		MsgProg:             l.Status.MsgProg,
 		MessageCountInQueue: len(l.Messages),
 	}
+	l.servedLock.Unlock()
+	loopData.UIDataChan <- data
 }
 
 func (l *Loop) processCoverageAndAppendMsg(cov []trace.CoverageBlock) {
@@ -418,11 +509,16 @@ func (l *Loop) Stop() {
 		}
 	}
 	l.Events.StopCapture()
+	if l.Injection != nil {
+		l.Injection.Close()
+	}
 }
 
 func (l *Loop) sendFirstChainMessages(msgs []LoopMessage) error {
 	for i := 0; i < len(msgs); i++ {
-		if _, err := l.runIterationWithData(msgs[i].Path, msgs[i].Message); err != nil {
+		_, err := l.runIterationWithData(msgs[i].Path, msgs[i].Message)
+		l.sendToSecondTarget(msgs[i].Path, msgs[i].Message)
+		if err != nil {
 			return errors.WithMessage(err, "Error occured while sending chain message!")
 		}
 	}
@@ -430,35 +526,14 @@ func (l *Loop) sendFirstChainMessages(msgs []LoopMessage) error {
 }
 
 func (l *Loop) runIterationWithData(path string, data []byte) (protoiface.MessageV1, error) {
-	curIterData := l.Context.Value("data").(models.ContextData)
-	endpoint := fmt.Sprintf("%s:%d", curIterData.Settings.Host, curIterData.Settings.Port)
-	protoFiles := util.GetFileFullPathInDirectory(curIterData.Settings.ProtoFilesPath, []string{"Includes"})
-
-	req := communication.GIPCRequest{
-		Endpoint:          endpoint,
-		Path:              path,
-		Data:              data,
-		ProtoFiles:        protoFiles,
-		ProtoIncludesPath: curIterData.Settings.ProtoFilesIncludePath,
-	}
-
-	return communication.SendRequestWithMessage(req)
+	started := time.Now()
+	response, err := l.Transport.Send(path, data)
+	l.lastLatency = time.Since(started)
+	return response, err
 }
 
 func (l *Loop) getMesasageEnergyData(path string, data []byte) (int, []trace.CoverageBlock, error) {
-	curIterData := l.Context.Value("data").(models.ContextData)
-	endpoint := fmt.Sprintf("%s:%d", curIterData.Settings.Host, curIterData.Settings.Port)
-	protoFiles := util.GetFileFullPathInDirectory(curIterData.Settings.ProtoFilesPath, []string{"Includes"})
-
-	req := communication.GIPCRequest{
-		Endpoint:          endpoint,
-		Path:              path,
-		Data:              data,
-		ProtoFiles:        protoFiles,
-		ProtoIncludesPath: curIterData.Settings.ProtoFilesIncludePath,
-	}
-
-	_, err := communication.SendRequestWithMessage(req)
+	_, err := l.Transport.Send(path, data)
 	if err != nil {
 		return 0, nil, err
 	}
@@ -482,8 +557,6 @@ func (l *Loop) getMesasageEnergyData(path string, data []byte) (int, []trace.Cov
 
 func (l *Loop) getMesasageChainEnergyData(msgChain DependentMsgChain, handler config.Handler) (int, []trace.CoverageBlock, error) {
 	curIterData := l.Context.Value("data").(models.ContextData)
-	endpoint := fmt.Sprintf("%s:%d", curIterData.Settings.Host, curIterData.Settings.Port)
-	protoFiles := util.GetFileFullPathInDirectory(curIterData.Settings.ProtoFilesPath, []string{"Includes"})
 	procName := filepath.Base(curIterData.Settings.PathToExecutable)
 
 	if len(msgChain.Messages) == 1 {
@@ -503,26 +576,20 @@ func (l *Loop) getMesasageChainEnergyData(msgChain DependentMsgChain, handler co
 	}
 
 	for i := 0; i < len(msgChain.Messages)-1; i++ {
-		if _, err := l.runIterationWithData(msgChain.Messages[i].Path, msgChain.Messages[i].Message); err != nil {
+		_, err := l.runIterationWithData(msgChain.Messages[i].Path, msgChain.Messages[i].Message)
+		l.sendToSecondTarget(msgChain.Messages[i].Path, msgChain.Messages[i].Message)
+		if err != nil {
 			return 0, nil, errors.WithMessage(err, "Error occured while sending trailing chain message!")
 		}
 	}
 
 	lastMsg := msgChain.Messages[len(msgChain.Messages)-1]
 
-	req := communication.GIPCRequest{
-		Endpoint:          endpoint,
-		Path:              lastMsg.Path,
-		Data:              lastMsg.Message,
-		ProtoFiles:        protoFiles,
-		ProtoIncludesPath: curIterData.Settings.ProtoFilesIncludePath,
-	}
-
 	if err := l.Trace.Start(procName, handler); err != nil {
 		return 0, nil, errors.WithMessage(err, "Failed to start tracing session for the energy calculation!")
 	}
 
-	_, err := communication.SendRequestWithMessage(req)
+	_, err := l.Transport.Send(lastMsg.Path, lastMsg.Message)
 	if err != nil {
 		return 0, nil, err
 	}
@@ -550,10 +617,7 @@ func (l *Loop) getMesasageChainEnergyData(msgChain DependentMsgChain, handler co
 
 func (l *Loop) initializeLoop() {
 	loopData := l.Context.Value("data").(models.ContextData)
-	messages := packet.GetParsedMessages(
-		loopData.Settings.PcapFilePath,
-		loopData.Settings.ProtoFilesPath,
-		loopData.Settings.ProtoFilesIncludePath)
+	messages := l.loadMessages()
 
 	if len(messages) == 0 {
 		l.Logger.LogError("No messages were processed! Bailing out...")
@@ -591,6 +655,37 @@ func (l *Loop) initializeLoop() {
 			os.Exit(1)
 		}
 	}
+	l.configureTimeout()
+}
+
+// loadMessages reads the seeds from the packet capture and the corpus
+func (l *Loop) loadMessages() []packet.ProtoByteMsg {
+	loopData := l.Context.Value("data").(models.ContextData)
+	var messages []packet.ProtoByteMsg
+	if loopData.Settings.Transport == transport.RawTransport {
+		messages = packet.GetParsedRawMessages(
+			loopData.Settings.PcapFilePath,
+			loopData.Settings.ProtoFilesPath,
+			loopData.Settings.ProtoFilesIncludePath,
+			loopData.Settings.RawRequestType,
+			loopData.Settings.RawResponseType,
+			util.GetTargetPort(loopData.Settings))
+	} else {
+		if len(loopData.Settings.PcapFilePath) > 0 {
+			messages = packet.GetParsedMessages(
+				loopData.Settings.PcapFilePath,
+				loopData.Settings.ProtoFilesPath,
+				loopData.Settings.ProtoFilesIncludePath)
+		}
+		// Seeds recorded by the proxy are used together with the captured ones
+		if corpusPath := util.GetCorpusPath(loopData.Settings); util.DirectoryExists(corpusPath) {
+			messages = append(messages, packet.GetCorpusMessages(
+				corpusPath,
+				loopData.Settings.ProtoFilesPath,
+				loopData.Settings.ProtoFilesIncludePath)...)
+		}
+	}
+	return messages
 }
 
 func (l *Loop) prepareMessageChains(msgs []packet.ProtoByteMsg) {
@@ -724,14 +819,7 @@ func (l *Loop) calculateMessageChainEnergy() error {
 			}
 		}
 
-		ticker := time.NewTicker(1 * time.Second)
-		for range ticker.C {
-			if watcher.IsProcessRunning(l.Context) {
-				break
-			}
-		}
-		ticker.Stop()
-		ticker = nil
+		l.waitForTarget()
 
 		tExec, cov, _ := l.getMesasageChainEnergyData(l.MessageChains[i], hnd)
 		l.MessageChains[i].Messages[len(l.MessageChains[i].Messages)-1].Coverage = append(l.MessageChains[i].Messages[len(l.MessageChains[i].Messages)-1].Coverage, cov...)
@@ -746,7 +834,12 @@ func (l *Loop) calculateMessageChainEnergy() error {
 
 	util.ScaleIntegers(covLenArr, 1, 10)
 	util.ScaleIntegers(fCountArr, 1, 10)
-	util.ScaleIntegersReverse(timeArr, 1, 10)
+	if loopData.Settings.Scheduling == SchedulingPerf {
+		// Slow messages get more energy, since the mutations try to make them even slower
+		util.ScaleIntegers(timeArr, 1, 10)
+	} else {
+		util.ScaleIntegersReverse(timeArr, 1, 10)
+	}
 
 	for i := 0; i < len(l.MessageChains); i++ {
 		l.MessageChains[i].Energy += covLenArr[i]
@@ -786,14 +879,7 @@ func (l *Loop) calculateMessagesEnergy() error {
 			}
 		}
 
-		ticker := time.NewTicker(1 * time.Second)
-		for range ticker.C {
-			if watcher.IsProcessRunning(l.Context) {
-				break
-			}
-		}
-		ticker.Stop()
-		ticker = nil
+		l.waitForTarget()
 
 		if err := l.Trace.Start(procName, hnd); err != nil {
 			return errors.WithMessage(err, "Failed to start tracing session for the energy calculation!")
@@ -816,7 +902,12 @@ func (l *Loop) calculateMessagesEnergy() error {
 
 	util.ScaleIntegers(covLenArr, 1, 10)
 	util.ScaleIntegers(fCountArr, 1, 10)
-	util.ScaleIntegersReverse(timeArr, 1, 10)
+	if loopData.Settings.Scheduling == SchedulingPerf {
+		// Slow messages get more energy, since the mutations try to make them even slower
+		util.ScaleIntegers(timeArr, 1, 10)
+	} else {
+		util.ScaleIntegersReverse(timeArr, 1, 10)
+	}
 
 	for i := 0; i < len(l.Messages); i++ {
 		l.Messages[i].Energy += covLenArr[i]
@@ -841,39 +932,52 @@ func (l *Loop) handleProcessStart() {
 	startProgStatus := make(chan *watcher.StartProcessResponse)
 
 	if !watcher.IsProcessRunning(l.Context) {
-		go watcher.StartProcess(l.Context, startProgStatus)
+		// Loop goes on with the next messages once the new process is up, so the crashing one is kept aside
+		crashMessage := l.currentSnapshot()
 
+		// Dump is prepared before the target is started, so it covers the target from the start
 		dumpPath := ""
 		var err error
-
 		if loopData.Settings.PerformMemoryDump {
 			dumpPath, err = l.MemDump.StartDump()
 			if err != nil {
 				l.Logger.LogError(err.Error())
 			}
 		}
+		go watcher.StartProcess(l.Context, startProgStatus)
 
 		for done := false; !done; {
 			select {
 			case <-l.Context.Done():
-				close(startProgStatus)
 				done = true
 			case response := <-startProgStatus:
 				if response != nil && response.Error != nil {
 					l.Logger.LogError(response.Error.Error())
 					done = true
 				}
-				if response != nil && response.Error == nil && len(response.Output) > 0 && response.Output != "EXIT" && l.CurrentMessage != nil {
-					l.Status.LastCrashTime = time.Now()
-					l.Status.UniqueCrashCount += 1 //TODO: it's unique crash count, so we need to calculate how many UNIQUE crashes occured
-					l.writeIterationCrash(response.Output, dumpPath, l.CurrentMessage.Message)
+				if response != nil && response.LastExit != nil && response.LastExit.TimedOut {
+					l.Logger.LogWarning(fmt.Sprintf("Process %d was killed after reaching the wall-clock limit", response.LastExit.Pid))
+				}
+				if response != nil && response.LastExit != nil && !watcher.ClassifyExitStatus(response.LastExit).IsCrash {
+					// The previous process has exited cleanly or was stopped by the fuzzer
 					done = true
+					continue
 				}
-				if response != nil && response.Error == nil && response.Output == "EXIT" && l.CurrentMessage != nil {
+				if response != nil && watcher.MayHaveDumpedCore(response.LastExit) && loopData.Settings.PerformMemoryDump {
+					// Core of the crashed process is only available after it has exited
+					if corePath, err := l.MemDump.CollectDump(response.LastExit.Pid); err != nil {
+						l.Logger.LogError(err.Error())
+					} else if len(corePath) > 0 {
+						dumpPath = corePath
+					}
+				}
+				if response != nil && response.Error == nil && len(response.Output) > 0 && response.Output != "EXIT" && crashMessage != nil {
+					l.countCrash(l.writeIterationCrash(response.Output, dumpPath, response.LastExit, crashMessage))
+					done = true
+				}
+				if response != nil && response.Error == nil && response.Output == "EXIT" && crashMessage != nil {
 					// TODO: something is wrong, need to check
-					l.Status.LastCrashTime = time.Now()
-					l.Status.UniqueCrashCount += 1 //TODO: it's unique crash count, so we need to calculate how many UNIQUE crashes occured
-					l.writeIterationCrash("", dumpPath, l.CurrentMessage.Message)
+					l.countCrash(l.writeIterationCrash("", dumpPath, response.LastExit, crashMessage))
 					done = true
 				}
 			default:
@@ -890,7 +994,6 @@ func (l *Loop) handleProcessStartWithoutReporting() {
 		for done := false; !done; {
 			select {
 			case <-l.Context.Done():
-				close(startProgStatus)
 				done = true
 			case response := <-startProgStatus:
 				if response != nil && response.Error != nil {
@@ -909,7 +1012,82 @@ func (l *Loop) handleProcessStartWithoutReporting() {
 	}
 }
 
-func (l *Loop) writeIterationCrash(processOutput, memoryDumpPath string, lastMessage []byte) {
+// countCrash updates the crash statistics, only the crashes with a new signature are unique
+func (l *Loop) countCrash(isNew bool) {
+	l.Status.TotalCrashCount += 1
+	if isNew {
+		l.Status.LastCrashTime = time.Now()
+		l.Status.UniqueCrashCount += 1
+	}
+}
+
+// countHang updates the hang statistics, only the hangs with a new signature are unique
+func (l *Loop) countHang(isNew bool) {
+	l.Status.TotalHangCount += 1
+	if isNew {
+		l.Status.LastHangTime = time.Now()
+		l.Status.UniqueHangCount += 1
+	}
+}
+
+// snapshotHang dumps the memory of the hanging process, so it can be inspected later
+func (l *Loop) snapshotHang() string {
+	loopData := l.Context.Value("data").(models.ContextData)
+	if !loopData.Settings.PerformMemoryDump {
+		return ""
+	}
+
+	pid := watcher.GetProcessPid(l.Context)
+	if pid == 0 {
+		return ""
+	}
+	dumpPath, err := l.MemDump.SnapshotProcess(pid)
+	if err != nil {
+		l.Logger.LogError(err.Error())
+		return ""
+	}
+	l.Logger.LogInfo(fmt.Sprintf("Hang snapshot was saved to %s", dumpPath))
+	return dumpPath
+}
+
+// writeIterationHang saves the call which has run out of time and returns true if it has a new signature.
+// The stack of the busy thread of the traced target gives the signature the same way as for the crashes,
+// without it the hangs are bucketed by the method. The snapshot is taken before the target is restarted.
+func (l *Loop) writeIterationHang(err error) bool {
+	hangOutput := output.CrashOutput{
+		ErrorCode:    codes.DeadlineExceeded.String(),
+		ErrorCause:   hangCategory,
+		ErrorDetails: err.Error(),
+		CrashMessage: fmt.Sprintf("%x", l.CurrentMessage.Message),
+		MethodPath:   l.CurrentMessage.Path,
+	}
+	if hangContext := watcher.CaptureHangContext(l.Context); hangContext != nil && len(hangContext.Backtrace) > 0 {
+		hangOutput.Context = hangContext
+		hangOutput.StackTrace = hangContext.Backtrace
+		l.Symbols.SymbolizeFrames(hangOutput.StackTrace)
+		hangOutput.Signature = triage.Signature(&hangOutput)
+	} else {
+		hangOutput.Signature = triage.HangSignature(hangOutput.MethodPath, hangOutput.ErrorCode)
+	}
+
+	// Snapshot is taken only once per signature, while the target still hangs
+	if !l.hangSignatures[hangOutput.Signature] {
+		if l.hangSignatures == nil {
+			l.hangSignatures = map[string]bool{}
+		}
+		l.hangSignatures[hangOutput.Signature] = true
+		hangOutput.MemoryDumpPath = l.snapshotHang()
+	}
+
+	isNew, saveErr := l.Output.SaveHang(&hangOutput)
+	if saveErr != nil {
+		l.Logger.LogError(saveErr.Error())
+	}
+	return isNew
+}
+
+// writeIterationCrash saves the crash and returns true if it has a new signature
+func (l *Loop) writeIterationCrash(processOutput, memoryDumpPath string, exitStatus *watcher.ExitStatus, lastMessage *LoopMessage) bool {
 	loopData := l.Context.Value("data").(models.ContextData)
 	events := l.Events.GetEventData()
 	crashOutput := output.CrashOutput{
@@ -917,18 +1095,30 @@ func (l *Loop) writeIterationCrash(processOutput, memoryDumpPath string, lastMes
 		ErrorCause:       "",
 		ModuleName:       "",
 		FaultFunction:    "",
-		MethodPath:       l.CurrentMessage.Path,
+		MethodPath:       lastMessage.Path,
 		ExecutableOutput: processOutput,
 		ExecutableEvents: events,
 		MemoryDumpPath:   memoryDumpPath,
-		CrashMessage:     fmt.Sprintf("%x", lastMessage),
+		CrashMessage:     fmt.Sprintf("%x", lastMessage.Message),
 	}
-	if methodHandler := util.GetMethodHandler(l.CurrentMessage.Path, loopData.Settings.Handlers); methodHandler != nil {
+	if methodHandler := util.GetMethodHandler(lastMessage.Path, loopData.Settings.Handlers); methodHandler != nil {
 		crashOutput.ModuleName = methodHandler.Module
 		crashOutput.FaultFunction = methodHandler.HandlerName
 	}
 
-	if len(processOutput) > 0 {
+	if exitStatus != nil {
+		// POSIX targets are classified by the exit status instead of parsing the output
+		crashClass := watcher.ClassifyExitStatus(exitStatus)
+		crashOutput.ErrorCode = crashClass.Code
+		crashOutput.ErrorCause = crashClass.Category
+		crashOutput.ErrorDetails = crashClass.Cause
+		crashOutput.Signal = exitStatus.Signal
+		crashOutput.ExitCode = exitStatus.ExitCode
+		crashOutput.CoreDumped = exitStatus.CoreDumped
+		if len(processOutput) == 0 {
+			crashOutput.ExecutableOutput = exitStatus.Output
+		}
+	} else if len(processOutput) > 0 {
 		crashOutput.ErrorCode = watcher.ParseErrorCode(processOutput)
 		crashOutput.ErrorCause = watcher.ExplainErrorCode(crashOutput.ErrorCode)
 	} else {
@@ -938,70 +1128,104 @@ func (l *Loop) writeIterationCrash(processOutput, memoryDumpPath string, lastMes
 		}
 	}
 
-	if err := l.Output.SaveCrash(&crashOutput); err != nil {
-		l.Logger.LogError(err.Error())
+	// Sanitizer and runtime panic reports give more details than the exit status or the error code
+	if report := triage.ParseReport(crashOutput.ExecutableOutput); report != nil {
+		report.Apply(&crashOutput)
 	}
-}
 
-func (l *Loop) handleIterationErr(err error) {
-	convertedErr := util.ConvertError(err)
-	if convertedErr == models.NetworkError {
-		if !watcher.IsProcessRunning(l.Context) {
-			go l.handleProcessStart()
-
-			ticker := time.NewTicker(1 * time.Second)
-			for range ticker.C {
-				if watcher.IsProcessRunning(l.Context) {
-					break
-				}
-			}
-			ticker.Stop()
-			l.Status.LastCrashTime = time.Now()
-			l.Status.UniqueCrashCount += 1 //TODO: it's unique crash count, so we need to calculate how many UNIQUE crashes occured
-		} else {
-			l.Status.LastHangTime = time.Now()
-			l.Status.UniqueHangCount += 1 //TODO: it's unique hang count, so we need to calculate how many UNIQUE hangs occured
+	// Context captured with ptrace fills in what the report did not give
+	if exitStatus != nil && exitStatus.Context != nil {
+		crashOutput.Context = exitStatus.Context
+		if len(crashOutput.FaultAddress) == 0 {
+			crashOutput.FaultAddress = exitStatus.Context.FaultAddress
+		}
+		if len(crashOutput.StackTrace) == 0 && len(exitStatus.Context.Backtrace) > 0 {
+			crashOutput.StackTrace = exitStatus.Context.Backtrace
+			frame := exitStatus.Context.Backtrace[0]
+			crashOutput.FaultingFrame = &frame
 		}
 	}
-	if convertedErr == models.GRPCError {
-		//log.Printf("gRPC request failed with error: %s", err)
-		if !watcher.IsProcessRunning(l.Context) {
-			go l.handleProcessStart()
 
-			ticker := time.NewTicker(1 * time.Second)
-			for range ticker.C {
-				if watcher.IsProcessRunning(l.Context) {
-					break
-				}
-			}
-			ticker.Stop()
-			l.Status.LastCrashTime = time.Now()
-			l.Status.UniqueCrashCount += 1 //TODO: it's unique crash count, so we need to calculate how many UNIQUE crashes occured
-		} else {
-			l.Status.LastHangTime = time.Now()
-			l.Status.UniqueHangCount += 1 //TODO: it's unique hang count, so we need to calculate how many UNIQUE hangs occured
+	// Frames which have only the module and offset are symbolized with the debug information of the module
+	l.Symbols.SymbolizeFrames(crashOutput.StackTrace)
+	if crashOutput.FaultingFrame != nil {
+		l.Symbols.SymbolizeFrame(crashOutput.FaultingFrame)
+		if len(crashOutput.FaultingFrame.Function) > 0 {
+			crashOutput.FaultFunction = crashOutput.FaultingFrame.Function
 		}
 	}
-	if convertedErr == models.UnknownError {
-		//log.Printf("gRPC request failed with error: %s", err)
-		if !watcher.IsProcessRunning(l.Context) {
-			go l.handleProcessStart()
 
-			ticker := time.NewTicker(1 * time.Second)
-			for range ticker.C {
-				if watcher.IsProcessRunning(l.Context) {
-					break
-				}
-			}
-			ticker.Stop()
-			l.Status.LastCrashTime = time.Now()
-			l.Status.UniqueCrashCount += 1 //TODO: it's unique crash count, so we need to calculate how many UNIQUE crashes occured
+	crashOutput.Signature = triage.Signature(&crashOutput)
+	isNew, err := l.Output.SaveCrash(&crashOutput)
+	if err != nil {
+		l.Logger.LogError(err.Error())
+	}
+	return isNew
+}
+
+// resolveHandlers replaces the function names of the handlers with the offsets from the debug information
+// of the module, so the names do not have to be exported. Names which cannot be resolved are left for
+// the instrumentation to look up in the exports.
+func (l *Loop) resolveHandlers() {
+	loopData := l.Context.Value("data").(models.ContextData)
+	for i := range loopData.Settings.Handlers {
+		handler := &loopData.Settings.Handlers[i]
+		if len(handler.HandlerName) == 0 || strings.HasPrefix(handler.HandlerName, "0x") {
+			continue
 		}
+
+		modulePath := handler.ModulePath
+		if len(modulePath) == 0 && handler.Module == filepath.Base(loopData.Settings.PathToExecutable) {
+			modulePath = loopData.Settings.PathToExecutable
+		}
+		if len(modulePath) == 0 {
+			continue
+		}
+
+		symbolizer, err := l.Symbols.Get(modulePath)
+		if err != nil {
+			l.Logger.LogWarning(err.Error())
+			continue
+		}
+		offset, err := symbolizer.ResolveName(handler.HandlerName)
+		if err != nil {
+			l.Logger.LogWarning(err.Error())
+			continue
+		}
+		l.Logger.LogInfo(fmt.Sprintf("Resolved handler %s of %s to 0x%x", handler.HandlerName, handler.Method, offset))
+		handler.HandlerName = fmt.Sprintf("0x%x", offset)
+	}
+}
+
+func (l *Loop) handleIterationErr(err error) {
+	if util.ConvertError(err) == models.Success {
+		return
 	}
+	if !watcher.IsProcessRunning(l.Context) {
+		go l.handleProcessStart()
+
+		// Crash is counted when it is saved after the restart
+		l.waitForTarget()
+		return
+	}
+	// Only the calls which ran out of time on the live process are hangs, other errors are the answers of the target
+	if util.IsTimeoutError(err) && l.CurrentMessage != nil {
+		l.countHang(l.writeIterationHang(err))
+		l.restartHungTarget()
+	}
+}
+
+// restartHungTarget kills the target once the hang is saved, since the hanging call keeps it busy and the
+// following calls would time out too. The exit is caused by the fuzzer, so it is not reported as a crash.
+func (l *Loop) restartHungTarget() {
+	watcher.KillProcess(l.Context)
+	go l.handleProcessStartWithoutReporting()
+	l.waitForTarget()
 }
 
 func (l *Loop) performDryRun() error {
 	sampleMessage := l.Messages[0]
 	_, err := l.runIterationWithData(sampleMessage.Path, sampleMessage.Message)
+	l.sendToSecondTarget(sampleMessage.Path, sampleMessage.Message)
 	return err
 }
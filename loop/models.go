@@ -15,6 +15,16 @@ type LoopMessage struct {
 	Message    []byte
 }
 
+// snapshot copies the message, so it stays the same while the loop goes on, or returns nil for no message
+func (m *LoopMessage) snapshot() *LoopMessage {
+	if m == nil {
+		return nil
+	}
+	msg := *m
+	msg.Message = append([]byte(nil), m.Message...)
+	return &msg
+}
+
 type LoopStatus struct {
 	NewPathTime      time.Time
 	LastCrashTime    time.Time
@@ -23,8 +33,13 @@ type LoopStatus struct {
 	NewPathCount     int
 	UniqueCrashCount int
 	UniqueHangCount  int
-	TotalExec        float64
-	MsgProg          float64
+	TotalCrashCount  int
+	TotalHangCount   int
+	// Findings are the bugs which have not crashed the target
+	UniqueFindingCount int
+	TotalFindingCount  int
+	TotalExec          float64
+	MsgProg            float64
 }
 
 type DependentMsgChain struct {
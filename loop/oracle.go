@@ -0,0 +1,340 @@
+package loop
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/lukjok/gipcfuzz/models"
+	"github.com/lukjok/gipcfuzz/oracle"
+	"github.com/lukjok/gipcfuzz/output"
+	"github.com/lukjok/gipcfuzz/triage"
+	"github.com/lukjok/gipcfuzz/util"
+	"github.com/lukjok/gipcfuzz/watcher"
+	"google.golang.org/grpc/status"
+)
+
+// checkIteration runs the oracles which find the bugs that do not crash the target
+func (l *Loop) checkIteration(response proto.Message, err error) {
+	// Latency goes first, since the resource oracle repeats the call
+	if l.LatencyOracle != nil {
+		l.checkLatency(err)
+	}
+	if l.StatusOracle != nil && err != nil {
+		l.checkStatus(err)
+	}
+	if l.Invariants != nil && err == nil && response != nil {
+		l.checkInvariants(response)
+	}
+	if l.Differential != nil {
+		l.checkDifferential(response, err)
+	}
+	if l.Injection != nil {
+		l.checkInjections()
+	}
+	if l.ResourceOracle != nil {
+		l.checkResources()
+	}
+}
+
+// checkStatus saves the error status of the live target which is interesting for the method or reveals
+// its internals. Statuses of the crashed target are the crashes.
+func (l *Loop) checkStatus(err error) {
+	violation := l.StatusOracle.Check(l.CurrentMessage.Path, err)
+	if violation == nil || !watcher.IsProcessRunning(l.Context) {
+		return
+	}
+
+	description := fmt.Sprintf("Call returned %s: %s", violation.Code, violation.Message)
+	if len(violation.Leak) > 0 {
+		description = fmt.Sprintf("Error message of %s reveals %q", violation.Code, violation.Leak)
+	}
+	l.saveFinding(&output.Finding{
+		Kind:        output.FindingStatus,
+		MethodPath:  l.CurrentMessage.Path,
+		Description: description,
+		Message:     fmt.Sprintf("%x", l.CurrentMessage.Message),
+		Details: map[string]interface{}{
+			"code":          violation.Code.String(),
+			"statusMessage": violation.Message,
+			"statusDetails": violation.Details,
+			"leak":          violation.Leak,
+		},
+	}, violation.Code.String(), violation.Reason)
+}
+
+// checkInvariants evaluates the invariants of the method over the request and the decoded response
+func (l *Loop) checkInvariants(response proto.Message) {
+	if !l.Invariants.Has(l.CurrentMessage.Path) {
+		return
+	}
+	request := dynamic.NewMessage(l.CurrentMessage.Descriptor)
+	if err := request.Unmarshal(l.CurrentMessage.Message); err != nil {
+		return
+	}
+	dynResponse, err := dynamic.AsDynamicMessage(response)
+	if err != nil {
+		l.Logger.LogWarning(fmt.Sprintf("Failed to decode the response of %s: %s", l.CurrentMessage.Path, err))
+		return
+	}
+
+	violations, errs := l.Invariants.Check(l.CurrentMessage.Path, request, dynResponse)
+	for _, err := range errs {
+		l.Logger.LogWarning(err.Error())
+	}
+	if len(violations) == 0 {
+		return
+	}
+	requestJSON, _ := request.MarshalJSON()
+	responseJSON, _ := dynResponse.MarshalJSON()
+	for _, violation := range violations {
+		l.saveFinding(&output.Finding{
+			Kind:        output.FindingInvariant,
+			MethodPath:  l.CurrentMessage.Path,
+			Description: fmt.Sprintf("Invariant %s does not hold", violation.Expression),
+			Message:     fmt.Sprintf("%x", l.CurrentMessage.Message),
+			Details: map[string]interface{}{
+				"invariant": violation.Expression,
+				"request":   json.RawMessage(requestJSON),
+				"response":  json.RawMessage(responseJSON),
+			},
+		}, violation.Expression)
+	}
+}
+
+// checkDifferential sends the current message to the second target and compares the answers. Calls which did
+// not reach the handler of the fuzzed target are skipped, its crashes and hangs are found anyway. The second target
+// is not supervised, so the calls it did not answer are the divergences.
+func (l *Loop) checkDifferential(response proto.Message, err error) {
+	if !isHandlerAnswer(err) || !watcher.IsProcessRunning(l.Context) {
+		return
+	}
+	secondResponse, secondErr := l.SecondTransport.Send(l.CurrentMessage.Path, l.CurrentMessage.Message)
+	if !isHandlerAnswer(secondErr) {
+		l.saveUnansweredDivergence(err, secondErr)
+		return
+	}
+
+	divergence, dErr := l.Differential.Compare(response, err, secondResponse, secondErr)
+	if dErr != nil {
+		l.Logger.LogWarning(dErr.Error())
+		return
+	}
+	if divergence == nil {
+		return
+	}
+
+	description := fmt.Sprintf("Targets returned %s and %s", divergence.PrimaryCode, divergence.SecondaryCode)
+	if divergence.Reason == oracle.DivergenceResponse {
+		description = fmt.Sprintf("Responses differ in %s: %v and %v", divergence.Field, divergence.PrimaryValue, divergence.SecondaryValue)
+	}
+	l.saveFinding(&output.Finding{
+		Kind:        output.FindingDivergence,
+		MethodPath:  l.CurrentMessage.Path,
+		Description: description,
+		Message:     fmt.Sprintf("%x", l.CurrentMessage.Message),
+		Details: map[string]interface{}{
+			"reason":            divergence.Reason,
+			"primaryCode":       divergence.PrimaryCode.String(),
+			"secondaryCode":     divergence.SecondaryCode.String(),
+			"field":             divergence.Field,
+			"primaryValue":      divergence.PrimaryValue,
+			"secondaryValue":    divergence.SecondaryValue,
+			"primaryResponse":   divergence.PrimaryResponse,
+			"secondaryResponse": divergence.SecondaryResponse,
+		},
+	}, divergence.Reason, divergence.Bucket())
+}
+
+func (l *Loop) saveUnansweredDivergence(err, secondErr error) {
+	failure := "unreachable"
+	if util.IsTimeoutError(secondErr) {
+		failure = "timeout"
+	}
+	l.saveFinding(&output.Finding{
+		Kind:        output.FindingDivergence,
+		MethodPath:  l.CurrentMessage.Path,
+		Description: fmt.Sprintf("Second target did not answer the call which returned %s: %s", status.Code(err), secondErr),
+		Message:     fmt.Sprintf("%x", l.CurrentMessage.Message),
+		Details: map[string]interface{}{
+			"reason":      oracle.DivergenceUnanswered,
+			"primaryCode": status.Code(err).String(),
+			"failure":     failure,
+			"error":       secondErr.Error(),
+		},
+	}, oracle.DivergenceUnanswered, failure)
+}
+
+// sendToSecondTarget sends the message which is not fuzzed, e.g. the start of the chain, to the second target
+// as well, so both targets are in the same state when the fuzzed message is compared
+func (l *Loop) sendToSecondTarget(path string, data []byte) {
+	if l.SecondTransport == nil {
+		return
+	}
+	if _, err := l.SecondTransport.Send(path, data); !isHandlerAnswer(err) {
+		l.Logger.LogWarning(fmt.Sprintf("Second target did not answer %s: %s", path, err))
+	}
+}
+
+// isHandlerAnswer tells if the call was answered by the service, either with a response or with a status
+func isHandlerAnswer(err error) bool {
+	return err == nil || (!util.IsTimeoutError(err) && util.ConvertError(err) == models.GRPCError)
+}
+
+// injectCanary puts a canary payload into a string field of the mutated message now and then. The message of the
+// mutator is not changed, so the payload is only sent once.
+func (l *Loop) injectCanary() {
+	message := dynamic.NewMessage(l.CurrentMessage.Descriptor)
+	if err := message.Unmarshal(l.CurrentMessage.Message); err != nil {
+		return
+	}
+	canary, err := l.Injection.Inject(l.CurrentMessage.Path, message)
+	if err != nil {
+		l.Logger.LogWarning(fmt.Sprintf("Failed to inject the canary: %s", err))
+		return
+	}
+	if canary != nil {
+		l.CurrentMessage.Message = canary.Message
+	}
+}
+
+// checkInjections saves the canaries which have taken effect. Hits can come later than the call, so they are
+// saved with the message which has carried the canary rather than the current one.
+func (l *Loop) checkInjections() {
+	for _, hit := range l.Injection.Hits() {
+		canary := hit.Canary
+		l.saveFinding(&output.Finding{
+			Kind:        output.FindingInjection,
+			MethodPath:  canary.Method,
+			Description: fmt.Sprintf("Canary of the %s injection in %s has taken effect: %s", canary.Kind, canary.Field, hit.Evidence),
+			Message:     fmt.Sprintf("%x", canary.Message),
+			Details: map[string]interface{}{
+				"injection": canary.Kind,
+				"field":     canary.Field,
+				"payload":   canary.Payload,
+				"token":     canary.Token,
+				"evidence":  hit.Evidence,
+			},
+		}, canary.Kind, canary.Bucket())
+	}
+}
+
+// checkResources compares the resources of the target with the sample taken after the previous call. When the
+// call has left something behind, it is repeated to tell a leak from the normal growth of caches and pools.
+// Memory growth of every call is also added to the trend of its method, which finds the slow leaks.
+func (l *Loop) checkResources() {
+	pid := watcher.GetProcessPid(l.Context)
+	if pid == 0 {
+		l.lastUsage = nil
+		return
+	}
+	usage, err := watcher.SampleResources(pid)
+	if err != nil {
+		l.lastUsage = nil
+		return
+	}
+
+	before := l.lastUsage
+	l.lastUsage = usage
+	if before == nil || l.lastUsagePid != pid {
+		// Restarted target starts from scratch
+		l.lastUsagePid = pid
+		return
+	}
+
+	if violation := l.ResourceOracle.CheckTrend(pid, l.CurrentMessage.Path, *before, *usage); violation != nil {
+		l.saveResourceFinding(violation, "trend")
+	}
+	if violation := l.ResourceOracle.CheckJump(*before, *usage); violation != nil {
+		l.saveResourceFinding(violation, "jump")
+		return
+	}
+	if !l.ResourceOracle.IsLeakCandidate(*before, *usage) {
+		return
+	}
+
+	samples := make([]watcher.ResourceUsage, 0, l.ResourceOracle.RepeatRuns+1)
+	samples = append(samples, *usage)
+	for i := 0; i < l.ResourceOracle.RepeatRuns; i++ {
+		// Error statuses are normal answers to the fuzzed input, and the leaks on the error paths are common
+		_, err := l.runIterationWithData(l.CurrentMessage.Path, l.CurrentMessage.Message)
+		if err != nil && (util.IsTimeoutError(err) || !watcher.IsProcessRunning(l.Context)) {
+			l.handleIterationErr(err)
+			l.lastUsage = nil
+			return
+		}
+		sample, err := watcher.SampleResources(pid)
+		if err != nil {
+			l.lastUsage = nil
+			return
+		}
+		samples = append(samples, *sample)
+	}
+	l.lastUsage = &samples[len(samples)-1]
+
+	if violation := l.ResourceOracle.CheckGrowth(samples); violation != nil {
+		l.saveResourceFinding(violation, "leak")
+	}
+}
+
+// checkLatency compares the latency of the call with the other calls of the method. Only the answered calls
+// are counted, since the errors of the transport say nothing about the handler.
+func (l *Loop) checkLatency(err error) {
+	l.lastLatencyObs = nil
+	if err != nil && (util.IsTimeoutError(err) || util.ConvertError(err) != models.GRPCError) {
+		return
+	}
+
+	obs := l.LatencyOracle.Observe(l.CurrentMessage.Path, l.lastLatency)
+	l.lastLatencyObs = &obs
+	loopData := l.Context.Value("data").(models.ContextData)
+	if !obs.Outlier || !loopData.Settings.LatencyOracle.Enabled {
+		return
+	}
+
+	// Slowdowns are bucketed by the order of magnitude, so the worse ones are kept separately
+	slowdown := float64(l.lastLatency) / float64(obs.Median)
+	l.saveFinding(&output.Finding{
+		Kind:        output.FindingSlowInput,
+		MethodPath:  l.CurrentMessage.Path,
+		Description: fmt.Sprintf("Call took %s, %.1f times the median of the method", l.lastLatency, slowdown),
+		Message:     fmt.Sprintf("%x", l.CurrentMessage.Message),
+		Details: map[string]interface{}{
+			"latencyMs": l.lastLatency.Milliseconds(),
+			"medianMs":  obs.Median.Milliseconds(),
+			"slowdown":  slowdown,
+		},
+	}, fmt.Sprintf("x%d", int(math.Pow(10, math.Floor(math.Log10(slowdown))))))
+}
+
+func (l *Loop) saveResourceFinding(violation *oracle.ResourceViolation, trend string) {
+	l.saveFinding(&output.Finding{
+		Kind:        output.FindingResourceLeak,
+		MethodPath:  l.CurrentMessage.Path,
+		Description: violation.Description,
+		Message:     fmt.Sprintf("%x", l.CurrentMessage.Message),
+		Details: map[string]interface{}{
+			"resource": violation.Resource,
+			"trend":    trend,
+			"before":   violation.Before,
+			"after":    violation.After,
+		},
+	}, violation.Resource, trend)
+}
+
+// saveFinding stores the finding of the current message. Findings are bucketed by the kind, the method and the key.
+func (l *Loop) saveFinding(finding *output.Finding, key ...string) {
+	finding.Signature = triage.FindingSignature(finding.Kind, finding.MethodPath, key...)
+	isNew, err := l.Output.SaveFinding(finding)
+	if err != nil {
+		l.Logger.LogError(err.Error())
+	}
+
+	l.Status.TotalFindingCount += 1
+	if isNew {
+		l.Status.UniqueFindingCount += 1
+		l.Logger.LogInfo(fmt.Sprintf("New %s finding in %s: %s", finding.Kind, finding.MethodPath, finding.Description))
+	}
+}
@@ -0,0 +1,68 @@
+package loop
+
+import (
+	"time"
+
+	"github.com/jhump/protoreflect/dynamic"
+)
+
+// SchedulingPerf focuses the mutations on the inputs which make the calls slower
+const SchedulingPerf = "perf"
+
+// slowestInput is the slowest mutation of the current message, the next mutations start from it
+type slowestInput struct {
+	latency time.Duration
+	message []byte
+}
+
+// scheduleSlowest keeps the mutation which has slowed down the call and drops the others, same as PerfFuzz
+// keeps the inputs which maximize the execution counts. Calls which are the slowest of their method are queued
+// and fuzzed on their own once the current queue entry is done.
+func (l *Loop) scheduleSlowest(slowest *slowestInput, message *dynamic.Message) {
+	if l.lastLatencyObs == nil {
+		// Call was not answered, so its latency is not known
+		return
+	}
+
+	if l.lastLatency > slowest.latency {
+		slowest.latency = l.lastLatency
+		slowest.message = append([]byte{}, l.CurrentMessage.Message...)
+		if l.lastLatencyObs.NewMax {
+			l.slowQueue = append(l.slowQueue, LoopMessage{
+				Path:       l.CurrentMessage.Path,
+				Message:    slowest.message,
+				Descriptor: l.CurrentMessage.Descriptor,
+				Energy:     l.CurrentMessage.Energy,
+				Coverage:   l.CurrentMessage.Coverage,
+			})
+		}
+		return
+	}
+
+	if len(slowest.message) == 0 {
+		return
+	}
+	if err := message.Unmarshal(slowest.message); err != nil {
+		l.Logger.LogError(err.Error())
+		return
+	}
+	l.CurrentMessage.Message = append([]byte{}, slowest.message...)
+}
+
+// takeSlowQueue returns the queued slow inputs and empties the queue
+func (l *Loop) takeSlowQueue() []LoopMessage {
+	queue := l.slowQueue
+	l.slowQueue = nil
+	return queue
+}
+
+// slowChain is the chain with the slow input instead of its last message, so the input is sent after the same messages
+func slowChain(chain DependentMsgChain, slow LoopMessage) DependentMsgChain {
+	messages := make([]LoopMessage, 0, len(chain.Messages))
+	messages = append(messages, chain.Messages[:len(chain.Messages)-1]...)
+	return DependentMsgChain{
+		Energy:      chain.Energy,
+		Messages:    append(messages, slow),
+		DepMessages: chain.DepMessages,
+	}
+}
@@ -0,0 +1,100 @@
+package loop
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/lukjok/gipcfuzz/communication"
+	"github.com/lukjok/gipcfuzz/config"
+	"github.com/lukjok/gipcfuzz/models"
+	"github.com/lukjok/gipcfuzz/util"
+	"github.com/lukjok/gipcfuzz/watcher"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Ways to check that the target is ready
+const (
+	ReadinessConnect = "connect"
+	ReadinessHealth  = "health"
+	ReadinessRPC     = "rpc"
+)
+
+const (
+	defaultStartTimeout  = 60 * time.Second
+	defaultProbeTimeout  = time.Second
+	defaultProbeInterval = 200 * time.Millisecond
+)
+
+// waitForTarget blocks until the started process is alive and its target is ready to accept the calls.
+// It gives up after the start timeout, so the target which never becomes ready is handled as a hang.
+func (l *Loop) waitForTarget() {
+	loopData := l.Context.Value("data").(models.ContextData)
+	readiness := loopData.Settings.Readiness
+	startTimeout := durationOrDefault(readiness.StartTimeout, defaultStartTimeout)
+	probeInterval := durationOrDefault(readiness.ProbeInterval, defaultProbeInterval)
+
+	started := time.Now()
+	var lastErr error
+	for time.Since(started) < startTimeout {
+		// Liveness comes from the process started by the fuzzer, the probe only tells if it is ready
+		if watcher.IsProcessRunning(l.Context) {
+			if lastErr = l.probeTarget(loopData.Settings); lastErr == nil {
+				return
+			}
+		} else {
+			lastErr = errors.New("Process is not running")
+		}
+
+		select {
+		case <-l.Context.Done():
+			return
+		case <-time.After(probeInterval):
+		}
+	}
+	l.Logger.LogWarning(fmt.Sprintf("Target was not ready after %s: %s", startTimeout, lastErr))
+}
+
+func (l *Loop) probeTarget(settings config.Configuration) error {
+	readiness := settings.Readiness
+	endpoint := util.GetTargetEndpoint(settings)
+	probeTimeout := durationOrDefault(readiness.ProbeTimeout, defaultProbeTimeout)
+
+	switch readiness.Mode {
+	case ReadinessHealth:
+		return communication.ProbeHealth(endpoint, readiness.Service, settings.SSL, probeTimeout)
+	case ReadinessRPC:
+		return l.probeRPC(readiness, probeTimeout)
+	case ReadinessConnect, "":
+		return communication.ProbeTarget(endpoint, probeTimeout)
+	default:
+		return errors.Errorf("Unknown readiness mode %s!", readiness.Mode)
+	}
+}
+
+// probeRPC sends the readiness call. Any answer from the server means it is ready, even the error status.
+// The call has the probe timeout as its own deadline, so it never outlives the probe.
+func (l *Loop) probeRPC(readiness config.ReadinessConfig, timeout time.Duration) error {
+	request, err := hex.DecodeString(readiness.Request)
+	if err != nil {
+		return errors.Errorf("Failed to decode the readiness request: %s", err)
+	}
+
+	_, err = l.Transport.SendWithTimeout(readiness.Method, request, timeout)
+	if err == nil {
+		return nil
+	}
+	if st, ok := status.FromError(err); ok && st.Code() != codes.Unavailable && st.Code() != codes.DeadlineExceeded {
+		return nil
+	}
+	return errors.WithMessagef(err, "Readiness call %s has failed", readiness.Method)
+}
+
+func durationOrDefault(milliseconds int, defaultValue time.Duration) time.Duration {
+	if milliseconds <= 0 {
+		return defaultValue
+	}
+	return time.Duration(milliseconds) * time.Millisecond
+}
@@ -0,0 +1,220 @@
+package loop
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/lukjok/gipcfuzz/events"
+	"github.com/lukjok/gipcfuzz/models"
+	"github.com/lukjok/gipcfuzz/oracle"
+	"github.com/lukjok/gipcfuzz/output"
+	"github.com/lukjok/gipcfuzz/packet"
+	"github.com/lukjok/gipcfuzz/util"
+	"github.com/lukjok/gipcfuzz/watcher"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Outcomes of the replayed calls
+const (
+	ReplayMatch    = "match"
+	ReplayMismatch = "mismatch"
+	ReplayNewError = "new error"
+	// Call answered with another status than the captured error, e.g. succeeded where it was rejected
+	ReplayStatusChange = "status change"
+	ReplayCrash        = "crash"
+	ReplayHang         = "hang"
+	// Requests without the captured response are only sent, so the session state is the same as in the capture
+	ReplaySent = "sent"
+)
+
+// ReplayResult is the outcome of the captured call sent to the current target
+type ReplayResult struct {
+	Index       int
+	Method      string
+	Outcome     string
+	Description string
+}
+
+// Failed tells if the call did not behave as in the capture
+func (r ReplayResult) Failed() bool {
+	return r.Outcome != ReplayMatch && r.Outcome != ReplaySent
+}
+
+// Replay sends every captured request in the session order and compares the live responses with the captured
+// ones. Crashes and hangs are saved as usual, mismatches and errors instead of the captured responses are findings.
+func (l *Loop) Replay() ([]ReplayResult, error) {
+	loopData := l.Context.Value("data").(models.ContextData)
+	pairs := packet.PairResponses(l.loadMessages())
+	if len(pairs) == 0 {
+		return nil, errors.New("No captured requests were found for the replay!")
+	}
+
+	if err := l.Events.NewEventManager(events.DefaultWindowsQuery); err != nil {
+		l.Logger.LogError(err.Error())
+	}
+	l.Events.StartCapture()
+	defer l.Stop()
+
+	go l.handleProcessStart()
+	l.waitForTarget()
+	// Only the target launched by the fuzzer is stopped, the external one belongs to the supervisor of the user
+	if !loopData.Settings.External.Enabled {
+		defer watcher.KillProcess(l.Context)
+	}
+
+	// Captured calls are not calibrated, so only the configured timeout is used
+	timeout := defaultRequestTimeout
+	if loopData.Settings.RequestTimeout > 0 {
+		timeout = time.Duration(loopData.Settings.RequestTimeout) * time.Millisecond
+	}
+	l.Transport.SetTimeout(timeout)
+
+	comparer := oracle.NewDifferential(loopData.Settings.Replay.IgnoreFields)
+	results := make([]ReplayResult, 0, len(pairs))
+	for i, pair := range pairs {
+		select {
+		case <-l.Context.Done():
+			return results, nil
+		default:
+		}
+		if pair.Request.Message == nil || pair.Request.Descriptor == nil {
+			continue
+		}
+		data, err := hex.DecodeString(*pair.Request.Message)
+		if err != nil {
+			l.Logger.LogWarning(fmt.Sprintf("Failed to decode the captured request %d of %s", i+1, pair.Request.Path))
+			continue
+		}
+
+		l.CurrentMessage = &LoopMessage{
+			Path:       pair.Request.Path,
+			Descriptor: pair.Request.Descriptor,
+			Message:    data,
+		}
+		results = append(results, l.replayCall(comparer, pair, i+1))
+	}
+	return results, nil
+}
+
+func (l *Loop) replayCall(comparer *oracle.Differential, pair packet.MsgPair, index int) ReplayResult {
+	result := ReplayResult{Index: index, Method: l.CurrentMessage.Path, Outcome: ReplaySent}
+	response, err := l.runIterationWithData(l.CurrentMessage.Path, l.CurrentMessage.Message)
+	l.Status.TotalExec += 1
+
+	if err != nil && !watcher.IsProcessRunning(l.Context) {
+		result.Outcome = ReplayCrash
+		result.Description = err.Error()
+		l.handleIterationErr(err)
+		return result
+	}
+	if util.IsTimeoutError(err) {
+		result.Outcome = ReplayHang
+		result.Description = err.Error()
+		l.handleIterationErr(err)
+		return result
+	}
+
+	if pair.Status != codes.OK {
+		return l.compareCapturedStatus(result, pair.Status, response, err)
+	}
+	capturedResponse := decodeCaptured(pair.Response)
+	if capturedResponse == nil {
+		if err != nil {
+			result.Description = err.Error()
+		}
+		return result
+	}
+	capturedJSON, _ := capturedResponse.MarshalJSON()
+
+	if err != nil {
+		result.Outcome = ReplayNewError
+		result.Description = fmt.Sprintf("Returned an error instead of the captured response: %s", err)
+		l.saveReplayFinding(result, "error", map[string]interface{}{
+			"error":            err.Error(),
+			"capturedResponse": json.RawMessage(capturedJSON),
+		}, status.Code(err).String())
+		return result
+	}
+
+	divergence, dErr := comparer.Compare(capturedResponse, nil, response, nil)
+	if dErr != nil {
+		l.Logger.LogWarning(dErr.Error())
+		return result
+	}
+	if divergence == nil {
+		result.Outcome = ReplayMatch
+		return result
+	}
+	result.Outcome = ReplayMismatch
+	result.Description = fmt.Sprintf("Response differs in %s: captured %v, live %v", divergence.Field, divergence.PrimaryValue, divergence.SecondaryValue)
+	l.saveReplayFinding(result, "mismatch", map[string]interface{}{
+		"field":            divergence.Field,
+		"capturedValue":    divergence.PrimaryValue,
+		"liveValue":        divergence.SecondaryValue,
+		"capturedResponse": divergence.PrimaryResponse,
+		"liveResponse":     divergence.SecondaryResponse,
+	}, divergence.Bucket())
+	return result
+}
+
+// compareCapturedStatus compares the live answer with the error status which the call returned in the capture
+func (l *Loop) compareCapturedStatus(result ReplayResult, captured codes.Code, response proto.Message, err error) ReplayResult {
+	live := status.Code(err)
+	if live == captured {
+		result.Outcome = ReplayMatch
+		return result
+	}
+
+	result.Outcome = ReplayStatusChange
+	details := map[string]interface{}{
+		"capturedCode": captured.String(),
+		"liveCode":     live.String(),
+	}
+	if err != nil {
+		result.Description = fmt.Sprintf("Returned %s instead of the captured %s: %s", live, captured, err)
+		details["error"] = err.Error()
+	} else {
+		result.Description = fmt.Sprintf("Returned a response instead of the captured %s", captured)
+		if dm, dErr := dynamic.AsDynamicMessage(response); dErr == nil {
+			if liveJSON, mErr := dm.MarshalJSON(); mErr == nil {
+				details["liveResponse"] = json.RawMessage(liveJSON)
+			}
+		}
+	}
+	l.saveReplayFinding(result, "status", details, fmt.Sprintf("%s/%s", captured, live))
+	return result
+}
+
+// decodeCaptured returns the captured response or nil if the call was answered with an error or was not captured
+func decodeCaptured(captured *packet.ProtoByteMsg) *dynamic.Message {
+	if captured == nil || captured.Message == nil || captured.Descriptor == nil {
+		return nil
+	}
+	data, err := hex.DecodeString(*captured.Message)
+	if err != nil {
+		return nil
+	}
+	msg := dynamic.NewMessage(captured.Descriptor)
+	if err := msg.Unmarshal(data); err != nil {
+		return nil
+	}
+	return msg
+}
+
+func (l *Loop) saveReplayFinding(result ReplayResult, reason string, details map[string]interface{}, key string) {
+	details["reason"] = reason
+	details["index"] = result.Index
+	l.saveFinding(&output.Finding{
+		Kind:        output.FindingRegression,
+		MethodPath:  result.Method,
+		Description: result.Description,
+		Message:     fmt.Sprintf("%x", l.CurrentMessage.Message),
+		Details:     details,
+	}, reason, key)
+}
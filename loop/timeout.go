@@ -0,0 +1,107 @@
+package loop
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lukjok/gipcfuzz/models"
+	"github.com/lukjok/gipcfuzz/watcher"
+)
+
+const hangCategory = "Hang"
+
+const (
+	// Calls are not limited more than before the calibration
+	defaultRequestTimeout    = 10 * time.Second
+	minRequestTimeout        = 500 * time.Millisecond
+	defaultTimeoutMultiplier = 5.0
+	maxCalibrationSeeds      = 50
+)
+
+// configureTimeout sets the deadline of the calls. If it is not configured, the seeds are sent once and the
+// slowest of them multiplied by the timeout multiplier is used, so a valid call is not mistaken for a hang.
+func (l *Loop) configureTimeout() {
+	loopData := l.Context.Value("data").(models.ContextData)
+	if l.SecondTransport != nil {
+		// Second target is not calibrated, it gets the configured or the default timeout
+		secondTimeout := defaultRequestTimeout
+		if loopData.Settings.RequestTimeout > 0 {
+			secondTimeout = time.Duration(loopData.Settings.RequestTimeout) * time.Millisecond
+		}
+		l.SecondTransport.SetTimeout(secondTimeout)
+	}
+	if loopData.Settings.RequestTimeout > 0 {
+		l.Transport.SetTimeout(time.Duration(loopData.Settings.RequestTimeout) * time.Millisecond)
+		return
+	}
+
+	multiplier := loopData.Settings.TimeoutMultiplier
+	if multiplier <= 0 {
+		multiplier = defaultTimeoutMultiplier
+	}
+
+	l.Transport.SetTimeout(defaultRequestTimeout)
+	slowest, samples := l.measureSeeds()
+	if samples == 0 {
+		l.Logger.LogWarning(fmt.Sprintf("No seed was answered, request timeout is %s", defaultRequestTimeout))
+		return
+	}
+
+	timeout := time.Duration(float64(slowest) * multiplier)
+	if timeout < minRequestTimeout {
+		timeout = minRequestTimeout
+	}
+	if timeout > defaultRequestTimeout {
+		timeout = defaultRequestTimeout
+	}
+	l.Transport.SetTimeout(timeout)
+	l.Logger.LogInfo(fmt.Sprintf("Request timeout was calibrated to %s from %d seeds", timeout, samples))
+}
+
+// measureSeeds sends the seeds and returns the longest execution time of the answered ones
+func (l *Loop) measureSeeds() (time.Duration, int) {
+	seeds := l.calibrationSeeds()
+	var slowest time.Duration
+	samples := 0
+	for _, seed := range seeds {
+		l.waitForTarget()
+
+		started := time.Now()
+		_, err := l.runIterationWithData(seed.Path, seed.Message)
+		elapsed := time.Since(started)
+		l.sendToSecondTarget(seed.Path, seed.Message)
+		if err != nil && !watcher.IsProcessRunning(l.Context) {
+			go l.handleProcessStartWithoutReporting()
+			continue
+		}
+		samples++
+		if elapsed > slowest {
+			slowest = elapsed
+		}
+	}
+	return slowest, samples
+}
+
+// calibrationSeeds returns the last messages of the chains or the single messages
+func (l *Loop) calibrationSeeds() []LoopMessage {
+	seeds := make([]LoopMessage, 0, maxCalibrationSeeds)
+	if len(l.Messages) > 0 {
+		for _, msg := range l.Messages {
+			if len(seeds) == maxCalibrationSeeds {
+				break
+			}
+			seeds = append(seeds, msg)
+		}
+		return seeds
+	}
+
+	for _, chain := range l.MessageChains {
+		if len(seeds) == maxCalibrationSeeds {
+			break
+		}
+		if len(chain.Messages) > 0 {
+			seeds = append(seeds, chain.Messages[len(chain.Messages)-1])
+		}
+	}
+	return seeds
+}
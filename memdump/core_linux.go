@@ -0,0 +1,297 @@
+//go:build linux
+// +build linux
+
+package memdump
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	corePatternPath  = "/proc/sys/kernel/core_pattern"
+	coreUsesPidPath  = "/proc/sys/kernel/core_uses_pid"
+	coreWaitTimeout  = 5 * time.Second
+	coreWaitInterval = 200 * time.Millisecond
+	stopWaitTimeout  = time.Second
+	stopWaitInterval = 10 * time.Millisecond
+)
+
+// CoreDump collects the core files of the crashed target and writes the snapshots of the live one
+type CoreDump struct {
+	BinaryPath    string
+	DumpOutputDir string
+	// Working directory of the target, where the cores are written by the relative core pattern
+	WorkingDirectory string
+	// Error of raising the core dump limit, which is reported by StartDump
+	limitErr error
+}
+
+// NewMemoryDumpManager returns the core dump based memory dump manager. Dump tool is not needed on Linux.
+// The core dump limit is raised right away, so every target started afterwards inherits it.
+func NewMemoryDumpManager(binaryPath, workingDirectory, dumpOutputDir, dumpToolPath string) MemoryDumpManager {
+	dump := NewCoreDump(binaryPath, dumpOutputDir)
+	dump.WorkingDirectory = workingDirectory
+	dump.limitErr = raiseCoreLimit()
+	return dump
+}
+
+// NewCoreDump stores the dumps in the separate directory for every fuzzing run
+func NewCoreDump(binaryPath, outputDir string) *CoreDump {
+	return &CoreDump{
+		BinaryPath:    binaryPath,
+		DumpOutputDir: filepath.Join(outputDir, "Dumps", time.Now().Format("20060102150405")),
+	}
+}
+
+// StartDump makes sure the core dumps are enabled for the target which is about to be started. The path
+// of the dump is only known after the crash, so it is returned by CollectDump.
+func (c *CoreDump) StartDump() (string, error) {
+	if err := os.MkdirAll(c.DumpOutputDir, 0700); err != nil {
+		return "", errors.Errorf("Failed to create the dump directory: %s", err)
+	}
+	if c.limitErr != nil {
+		return "", c.limitErr
+	}
+	return "", nil
+}
+
+// raiseCoreLimit raises the core dump limit of the fuzzer to the hard limit. It is inherited by the started targets.
+func raiseCoreLimit() error {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_CORE, &limit); err != nil {
+		return errors.Errorf("Failed to get the core dump limit: %s", err)
+	}
+	if limit.Max == 0 {
+		return errors.New("Core dumps are disabled by the hard limit!")
+	}
+	if limit.Cur != limit.Max {
+		limit.Cur = limit.Max
+		if err := syscall.Setrlimit(syscall.RLIMIT_CORE, &limit); err != nil {
+			return errors.Errorf("Failed to set the core dump limit: %s", err)
+		}
+	}
+	return nil
+}
+
+// CollectDump moves the core file of the crashed process to the dump directory. Cores handled by
+// systemd-coredump are exported with coredumpctl.
+func (c *CoreDump) CollectDump(pid int) (string, error) {
+	if err := os.MkdirAll(c.DumpOutputDir, 0700); err != nil {
+		return "", errors.Errorf("Failed to create the dump directory: %s", err)
+	}
+
+	pattern, err := ioutil.ReadFile(corePatternPath)
+	if err != nil {
+		return "", errors.Errorf("Failed to read the core pattern: %s", err)
+	}
+	corePattern := strings.TrimSpace(string(pattern))
+	dumpPath := c.newDumpPath(pid, "core")
+
+	if strings.HasPrefix(corePattern, "|") {
+		return dumpPath, collectWithCoredumpctl(pid, dumpPath)
+	}
+
+	coreGlob := expandCorePattern(corePattern, pid, filepath.Base(c.BinaryPath))
+	if !filepath.IsAbs(coreGlob) {
+		// Relative pattern is resolved against the working directory of the target, which is ours by default
+		dir := c.WorkingDirectory
+		if !filepath.IsAbs(dir) {
+			wd, _ := os.Getwd()
+			dir = filepath.Join(wd, dir)
+		}
+		coreGlob = filepath.Join(dir, coreGlob)
+	}
+
+	corePath, err := waitForCoreFile(coreGlob)
+	if err != nil {
+		return "", err
+	}
+	if err := moveFile(corePath, dumpPath); err != nil {
+		return "", errors.Errorf("Failed to move the core file %s: %s", corePath, err)
+	}
+	return dumpPath, nil
+}
+
+// SnapshotProcess writes the ELF core of the live process. The process is stopped while its memory is read.
+func (c *CoreDump) SnapshotProcess(pid int) (string, error) {
+	if err := os.MkdirAll(c.DumpOutputDir, 0700); err != nil {
+		return "", errors.Errorf("Failed to create the dump directory: %s", err)
+	}
+
+	if err := syscall.Kill(pid, syscall.SIGSTOP); err != nil {
+		return "", errors.Errorf("Failed to stop the process %d: %s", pid, err)
+	}
+	defer syscall.Kill(pid, syscall.SIGCONT)
+	// Signal only starts the stop, the threads stop on their own afterwards
+	if err := waitForStop(pid); err != nil {
+		return "", err
+	}
+
+	dumpPath := c.newDumpPath(pid, "snapshot.core")
+	if err := WriteCore(pid, dumpPath); err != nil {
+		os.Remove(dumpPath)
+		return "", err
+	}
+	return dumpPath, nil
+}
+
+// waitForStop waits until every thread of the process is stopped, either by the signal or by its tracer
+func waitForStop(pid int) error {
+	deadline := time.Now().Add(stopWaitTimeout)
+	for {
+		tasks, err := filepath.Glob(fmt.Sprintf("/proc/%d/task/*/stat", pid))
+		if err != nil || len(tasks) == 0 {
+			return errors.Errorf("Failed to read the threads of the process %d", pid)
+		}
+		running := false
+		for _, task := range tasks {
+			stat, err := ioutil.ReadFile(task)
+			if err != nil {
+				// Thread has exited in the meantime
+				continue
+			}
+			if end := strings.LastIndex(string(stat), ")"); end > 0 {
+				fields := strings.Fields(string(stat[end+1:]))
+				// Stopped, traced or exiting threads do not change the memory
+				if len(fields) > 0 && !strings.ContainsAny(fields[0], "TtZX") {
+					running = true
+					break
+				}
+			}
+		}
+		if !running {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.Errorf("Process %d did not stop for the snapshot", pid)
+		}
+		time.Sleep(stopWaitInterval)
+	}
+}
+
+func (c *CoreDump) newDumpPath(pid int, suffix string) string {
+	dumpFileName := fmt.Sprintf("%s_%d_%s.%s", filepath.Base(c.BinaryPath), pid, time.Now().Format("20060102150405"), suffix)
+	return filepath.Join(c.DumpOutputDir, dumpFileName)
+}
+
+// expandCorePattern turns the core_pattern into the glob. Specifiers which cannot be known in advance
+// (e.g. the time of the crash) match anything.
+func expandCorePattern(pattern string, pid int, execName string) string {
+	if usesPid, err := ioutil.ReadFile(coreUsesPidPath); err == nil && strings.TrimSpace(string(usesPid)) == "1" && !strings.Contains(pattern, "%p") {
+		pattern += ".%p"
+	}
+
+	// Kernel uses the task name, which is truncated to 15 characters
+	if len(execName) > 15 {
+		execName = execName[:15]
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i+1 == len(pattern) {
+			sb.WriteByte(pattern[i])
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case '%':
+			sb.WriteByte('%')
+		case 'p', 'P':
+			sb.WriteString(fmt.Sprint(pid))
+		case 'u':
+			sb.WriteString(fmt.Sprint(os.Getuid()))
+		case 'g':
+			sb.WriteString(fmt.Sprint(os.Getgid()))
+		case 'e':
+			sb.WriteString(execName)
+		case 'h':
+			hostname, _ := os.Hostname()
+			sb.WriteString(hostname)
+		default:
+			sb.WriteString("*")
+		}
+	}
+	return sb.String()
+}
+
+// waitForCoreFile waits until the kernel finishes writing the core file, since it is written after the exit is reported
+func waitForCoreFile(coreGlob string) (string, error) {
+	deadline := time.Now().Add(coreWaitTimeout)
+	var lastSize int64 = -1
+	for time.Now().Before(deadline) {
+		matches, _ := filepath.Glob(coreGlob)
+		if len(matches) > 0 {
+			// Newest file belongs to the last crash
+			sort.Slice(matches, func(i, j int) bool {
+				fi, _ := os.Stat(matches[i])
+				fj, _ := os.Stat(matches[j])
+				return fi != nil && fj != nil && fi.ModTime().After(fj.ModTime())
+			})
+			if info, err := os.Stat(matches[0]); err == nil {
+				if info.Size() > 0 && info.Size() == lastSize {
+					return matches[0], nil
+				}
+				lastSize = info.Size()
+			}
+		}
+		time.Sleep(coreWaitInterval)
+	}
+	return "", errors.Errorf("Core file matching %s was not found", coreGlob)
+}
+
+func collectWithCoredumpctl(pid int, dumpPath string) error {
+	if _, err := exec.LookPath("coredumpctl"); err != nil {
+		return errors.New("Core dumps are piped to a helper and coredumpctl is not available!")
+	}
+
+	// systemd-coredump processes the core asynchronously, so it may not be there right after the exit
+	var lastErr error
+	deadline := time.Now().Add(coreWaitTimeout)
+	for time.Now().Before(deadline) {
+		cmd := exec.Command("coredumpctl", "dump", fmt.Sprint(pid), "--output="+dumpPath, "--no-pager", "--quiet")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			lastErr = errors.Errorf("coredumpctl failed: %s %s", err, strings.TrimSpace(string(out)))
+			time.Sleep(coreWaitInterval)
+			continue
+		}
+		return nil
+	}
+	return lastErr
+}
+
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	// Rename does not work across file systems
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
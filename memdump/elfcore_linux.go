@@ -0,0 +1,254 @@
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+package memdump
+
+import (
+	"bufio"
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	elfHeaderSize = 64
+	elfPhdrSize   = 56
+	memCopyChunk  = 1 << 20
+	// Memory beyond this size is left out of the snapshot, only the headers of such regions are written
+	maxCoreDataSize = 8 << 30
+	noteNameCore    = "CORE"
+	noteTypePrps    = 3
+	prpsinfoSize    = 136
+	prpsFnameOffset = 40
+	prpsFnameSize   = 16
+	prpsArgsOffset  = 56
+	prpsArgsSize    = 80
+)
+
+// Core is always written as the 64-bit ELF, so only the 64-bit architectures are supported
+var elfMachines = map[string]elf.Machine{
+	"amd64": elf.EM_X86_64,
+	"arm64": elf.EM_AARCH64,
+}
+
+// WriteCore writes the ELF core file with the readable memory of the process. Registers are not
+// included, since they cannot be read without attaching to the process. Only the regions with resident
+// or swapped out pages are copied, the rest are read as zeros by the debuggers like in the kernel cores.
+func WriteCore(pid int, path string) error {
+	machine, ok := elfMachines[runtime.GOARCH]
+	if !ok {
+		return errors.Errorf("Core snapshots are not supported on %s", runtime.GOARCH)
+	}
+
+	allRegions, err := ReadMemoryMap(pid)
+	if err != nil {
+		return errors.Errorf("Failed to read the memory map: %s", err)
+	}
+	resident, err := ReadResidentSizes(pid)
+	if err != nil {
+		return errors.Errorf("Failed to read the memory usage: %s", err)
+	}
+	regions := make([]MemoryRegion, 0, len(allRegions))
+	for _, region := range allRegions {
+		// Special kernel mappings cannot be read through the mem file
+		if region.Perms[0] != 'r' || region.Path == "[vvar]" || region.Path == "[vsyscall]" {
+			continue
+		}
+		regions = append(regions, region)
+	}
+
+	// Untouched regions (e.g. the reserved sanitizer shadow memory) and the ones past the limit are not copied
+	fileSizes := make([]uint64, len(regions))
+	var total uint64
+	for i, region := range regions {
+		size := region.End - region.Start
+		if resident[region.Start] == 0 || total+size > maxCoreDataSize {
+			continue
+		}
+		fileSizes[i] = size
+		total += size
+	}
+
+	mem, err := os.Open(fmt.Sprintf("/proc/%d/mem", pid))
+	if err != nil {
+		return errors.Errorf("Failed to open the process memory: %s", err)
+	}
+	defer mem.Close()
+
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	w := bufio.NewWriter(out)
+
+	note := prpsinfoNote(pid)
+	phnum := len(regions) + 1
+	dataOffset := uint64(elfHeaderSize + phnum*elfPhdrSize)
+
+	writeElfHeader(w, machine, phnum)
+
+	// Note segment is followed by the memory segments in the same order as the headers
+	writePhdr(w, elf.PT_NOTE, 0, dataOffset, 0, uint64(len(note)), 0)
+	offset := dataOffset + uint64(len(note))
+	for i, region := range regions {
+		writePhdr(w, elf.PT_LOAD, regionFlags(region.Perms), offset, region.Start, fileSizes[i], region.End-region.Start)
+		offset += fileSizes[i]
+	}
+
+	if _, err := w.Write(note); err != nil {
+		return err
+	}
+	for i, region := range regions {
+		if fileSizes[i] == 0 {
+			continue
+		}
+		if err := copyRegion(w, mem, region); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// copyRegion writes the region memory. Unreadable pages are written as zeros, so the offsets stay valid.
+func copyRegion(w io.Writer, mem *os.File, region MemoryRegion) error {
+	buf := make([]byte, memCopyChunk)
+	for addr := region.Start; addr < region.End; {
+		chunk := region.End - addr
+		if chunk > memCopyChunk {
+			chunk = memCopyChunk
+		}
+		n, err := mem.ReadAt(buf[:chunk], int64(addr))
+		if err != nil {
+			for i := n; i < int(chunk); i++ {
+				buf[i] = 0
+			}
+		}
+		if _, err := w.Write(buf[:chunk]); err != nil {
+			return err
+		}
+		addr += chunk
+	}
+	return nil
+}
+
+func regionFlags(perms string) elf.ProgFlag {
+	var flags elf.ProgFlag
+	if perms[0] == 'r' {
+		flags |= elf.PF_R
+	}
+	if perms[1] == 'w' {
+		flags |= elf.PF_W
+	}
+	if perms[2] == 'x' {
+		flags |= elf.PF_X
+	}
+	return flags
+}
+
+func writeElfHeader(w io.Writer, machine elf.Machine, phnum int) {
+	ident := [elf.EI_NIDENT]byte{0x7f, 'E', 'L', 'F', byte(elf.ELFCLASS64), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT)}
+	w.Write(ident[:])
+	binary.Write(w, binary.LittleEndian, struct {
+		Type      uint16
+		Machine   uint16
+		Version   uint32
+		Entry     uint64
+		Phoff     uint64
+		Shoff     uint64
+		Flags     uint32
+		Ehsize    uint16
+		Phentsize uint16
+		Phnum     uint16
+		Shentsize uint16
+		Shnum     uint16
+		Shstrndx  uint16
+	}{
+		Type:      uint16(elf.ET_CORE),
+		Machine:   uint16(machine),
+		Version:   uint32(elf.EV_CURRENT),
+		Phoff:     elfHeaderSize,
+		Ehsize:    elfHeaderSize,
+		Phentsize: elfPhdrSize,
+		Phnum:     uint16(phnum),
+	})
+}
+
+func writePhdr(w io.Writer, ptype elf.ProgType, flags elf.ProgFlag, offset, vaddr, filesz, memsz uint64) {
+	align := uint64(1)
+	if ptype == elf.PT_LOAD {
+		align = uint64(os.Getpagesize())
+	}
+	binary.Write(w, binary.LittleEndian, elf.Prog64{
+		Type:   uint32(ptype),
+		Flags:  uint32(flags),
+		Off:    offset,
+		Vaddr:  vaddr,
+		Filesz: filesz,
+		Memsz:  memsz,
+		Align:  align,
+	})
+}
+
+// prpsinfoNote builds the NT_PRPSINFO note, so the debuggers show the process name and arguments
+func prpsinfoNote(pid int) []byte {
+	desc := make([]byte, prpsinfoSize)
+	desc[0] = 'R'
+	desc[1] = 'R'
+
+	if status, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid)); err == nil {
+		stat := string(status)
+		if end := strings.LastIndex(stat, ")"); end > 0 {
+			fields := strings.Fields(stat[end+1:])
+			if len(fields) > 4 {
+				ppid, _ := strconv.Atoi(fields[1])
+				pgrp, _ := strconv.Atoi(fields[2])
+				sid, _ := strconv.Atoi(fields[3])
+				binary.LittleEndian.PutUint32(desc[28:], uint32(ppid))
+				binary.LittleEndian.PutUint32(desc[32:], uint32(pgrp))
+				binary.LittleEndian.PutUint32(desc[36:], uint32(sid))
+			}
+		}
+	}
+	binary.LittleEndian.PutUint32(desc[16:], uint32(os.Getuid()))
+	binary.LittleEndian.PutUint32(desc[20:], uint32(os.Getgid()))
+	binary.LittleEndian.PutUint32(desc[24:], uint32(pid))
+
+	if comm, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/comm", pid)); err == nil {
+		copy(desc[prpsFnameOffset:prpsFnameOffset+prpsFnameSize-1], strings.TrimSpace(string(comm)))
+	}
+	if cmdline, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid)); err == nil {
+		args := strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+		copy(desc[prpsArgsOffset:prpsArgsOffset+prpsArgsSize-1], args)
+	}
+
+	return buildNote(noteNameCore, noteTypePrps, desc)
+}
+
+func buildNote(name string, noteType uint32, desc []byte) []byte {
+	nameBuf := append([]byte(name), 0)
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint32(len(nameBuf)))
+	binary.Write(&buf, binary.LittleEndian, uint32(len(desc)))
+	binary.Write(&buf, binary.LittleEndian, noteType)
+	buf.Write(pad4(nameBuf))
+	buf.Write(pad4(desc))
+	return buf.Bytes()
+}
+
+func pad4(data []byte) []byte {
+	if rem := len(data) % 4; rem != 0 {
+		return append(data, make([]byte, 4-rem)...)
+	}
+	return data
+}
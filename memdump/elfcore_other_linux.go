@@ -0,0 +1,15 @@
+//go:build linux && !amd64 && !arm64
+// +build linux,!amd64,!arm64
+
+package memdump
+
+import (
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+// WriteCore is not supported, since the snapshots are written as the 64-bit ELF cores
+func WriteCore(pid int, path string) error {
+	return errors.Errorf("Core snapshots are not supported on %s", runtime.GOARCH)
+}
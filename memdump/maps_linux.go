@@ -0,0 +1,91 @@
+//go:build linux
+// +build linux
+
+package memdump
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// MemoryRegion is a single mapping from /proc/<pid>/maps
+type MemoryRegion struct {
+	Start  uint64
+	End    uint64
+	Perms  string
+	Offset uint64
+	Path   string
+}
+
+// ReadMemoryMap parses the memory map of the process
+func ReadMemoryMap(pid int) ([]MemoryRegion, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/maps", pid))
+	if err != nil {
+		return nil, err
+	}
+
+	regions := make([]MemoryRegion, 0, 64)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		// 7f1c2c000000-7f1c2c021000 rw-p 00000000 00:00 0    [heap]
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		bounds := strings.SplitN(fields[0], "-", 2)
+		if len(bounds) != 2 {
+			continue
+		}
+		start, err1 := strconv.ParseUint(bounds[0], 16, 64)
+		end, err2 := strconv.ParseUint(bounds[1], 16, 64)
+		offset, err3 := strconv.ParseUint(fields[2], 16, 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+		region := MemoryRegion{
+			Start:  start,
+			End:    end,
+			Perms:  fields[1],
+			Offset: offset,
+		}
+		if len(fields) > 5 {
+			region.Path = strings.Join(fields[5:], " ")
+		}
+		regions = append(regions, region)
+	}
+	return regions, scanner.Err()
+}
+
+// ReadResidentSizes returns the resident and swapped out bytes of every mapping by its start address.
+// Mappings without such pages (e.g. the reserved sanitizer shadow memory) were never touched.
+func ReadResidentSizes(pid int) (map[uint64]uint64, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/smaps", pid))
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := map[uint64]uint64{}
+	var start uint64
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		// Mapping lines are followed by the "Name: value kB" lines
+		if !strings.HasSuffix(fields[0], ":") {
+			bounds := strings.SplitN(fields[0], "-", 2)
+			start, _ = strconv.ParseUint(bounds[0], 16, 64)
+			continue
+		}
+		if (fields[0] == "Rss:" || fields[0] == "Swap:") && len(fields) > 1 {
+			kb, _ := strconv.ParseUint(fields[1], 10, 64)
+			sizes[start] += kb << 10
+		}
+	}
+	return sizes, scanner.Err()
+}
@@ -11,7 +11,12 @@ import (
 )
 
 type MemoryDumpManager interface {
+	// StartDump prepares the dump of the process which is about to be started
 	StartDump() (string, error)
+	// CollectDump returns the dump of the crashed process
+	CollectDump(pid int) (string, error)
+	// SnapshotProcess dumps the memory of the live process
+	SnapshotProcess(pid int) (string, error)
 }
 
 type MemoryDump struct {
@@ -53,3 +58,29 @@ func (m *MemoryDump) StartDump() (string, error) {
 
 	return fullDumpPath, nil
 }
+
+// CollectDump does nothing, since ProcDump started by StartDump writes the dump on the exception itself
+func (m *MemoryDump) CollectDump(pid int) (string, error) {
+	return "", nil
+}
+
+func (m *MemoryDump) SnapshotProcess(pid int) (string, error) {
+	if !util.FileExists(m.DumpToolPath) {
+		return "", errors.Errorf("Memory dump tool does not exist at given path: %v\n", m.DumpToolPath)
+	}
+
+	execName := filepath.Base(m.BinaryPath)
+	dumpFileName := fmt.Sprintf("%s_%d_%s", execName, pid, time.Now().Format("20060102150405"))
+	fullDumpPath := filepath.Join(m.DumpOutputDir, dumpFileName)
+	toolExecParams := []string{"-accepteula", "-ma", fmt.Sprint(pid), fullDumpPath}
+
+	cmd := exec.Command(m.DumpToolPath, toolExecParams...)
+	cmd.Dir = filepath.Dir(m.DumpToolPath)
+
+	// Process is not crashed, so the dump is written right away
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+
+	return fullDumpPath, nil
+}
@@ -0,0 +1,10 @@
+//go:build windows
+// +build windows
+
+package memdump
+
+// NewMemoryDumpManager returns the ProcDump based memory dump manager. ProcDump writes the dumps to the
+// output directory, so the working directory of the target is not needed.
+func NewMemoryDumpManager(binaryPath, workingDirectory, dumpOutputDir, dumpToolPath string) MemoryDumpManager {
+	return NewMemoryDump(binaryPath, dumpOutputDir, dumpToolPath)
+}
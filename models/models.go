@@ -30,6 +30,10 @@ type UIData struct {
 	TotalPaths          int
 	UniqCrash           int
 	UniqHangs           int
+	TotalCrashes        int
+	TotalHangs          int
+	UniqFindings        int
+	TotalFindings       int
 	TotalExec           float64
 	ExecSpd             float64
 	CurrMsg             string
@@ -0,0 +1,210 @@
+package oracle
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Reasons of the divergences
+const (
+	DivergenceStatus   = "status"
+	DivergenceResponse = "response"
+	// Second target did not answer the call which the fuzzed one did
+	DivergenceUnanswered = "unanswered"
+)
+
+var listIndex = regexp.MustCompile(`\[\d+\]`)
+
+// Divergence is the difference between two answers to the same input
+type Divergence struct {
+	Reason        string
+	PrimaryCode   codes.Code
+	SecondaryCode codes.Code
+	// Field is the path of the first differing field, e.g. items[2].price
+	Field             string
+	PrimaryValue      interface{}
+	SecondaryValue    interface{}
+	PrimaryResponse   json.RawMessage
+	SecondaryResponse json.RawMessage
+}
+
+// Bucket is the field path without the list indexes, so the same field of any element is one divergence
+func (d *Divergence) Bucket() string {
+	if d.Reason == DivergenceStatus {
+		return fmt.Sprintf("%s/%s", d.PrimaryCode, d.SecondaryCode)
+	}
+	return listIndex.ReplaceAllString(d.Field, "[]")
+}
+
+// Differential compares two answers to the same input, e.g. of two implementations of the service
+// or of the captured and the live target
+type Differential struct {
+	// Ignored field paths, the names without a dot are ignored at any depth
+	ignoredPaths map[string]bool
+	ignoredNames map[string]bool
+}
+
+func NewDifferential(ignoreFields []string) *Differential {
+	d := &Differential{
+		ignoredPaths: map[string]bool{},
+		ignoredNames: map[string]bool{},
+	}
+	for _, field := range ignoreFields {
+		if strings.Contains(field, ".") {
+			d.ignoredPaths[field] = true
+		} else {
+			d.ignoredNames[field] = true
+		}
+	}
+	return d
+}
+
+// Compare returns the divergence if the status codes of the calls differ or the responses differ
+// in any field which is not ignored. Status messages are not compared, since they are implementation specific.
+func (d *Differential) Compare(primary proto.Message, primaryErr error, secondary proto.Message, secondaryErr error) (*Divergence, error) {
+	primaryJSON, err := normalizedJSON(primary)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Failed to normalize the first response")
+	}
+	secondaryJSON, err := normalizedJSON(secondary)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Failed to normalize the second response")
+	}
+
+	divergence := &Divergence{
+		PrimaryCode:       status.Code(primaryErr),
+		SecondaryCode:     status.Code(secondaryErr),
+		PrimaryResponse:   primaryJSON,
+		SecondaryResponse: secondaryJSON,
+	}
+	if divergence.PrimaryCode != divergence.SecondaryCode {
+		divergence.Reason = DivergenceStatus
+		return divergence, nil
+	}
+	if divergence.PrimaryCode != codes.OK {
+		return nil, nil
+	}
+
+	primaryValue, err := decodeJSON(primaryJSON)
+	if err != nil {
+		return nil, err
+	}
+	secondaryValue, err := decodeJSON(secondaryJSON)
+	if err != nil {
+		return nil, err
+	}
+	field, found := d.diff(primaryValue, secondaryValue, nil, "", divergence)
+	if !found {
+		return nil, nil
+	}
+	divergence.Reason = DivergenceResponse
+	divergence.Field = field
+	return divergence, nil
+}
+
+// normalizedJSON encodes the response with the proto field names and without the default values,
+// so the unset and the zero fields are the same in both responses
+func normalizedJSON(msg proto.Message) (json.RawMessage, error) {
+	if msg == nil {
+		return nil, nil
+	}
+	dm, err := dynamic.AsDynamicMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	data, err := dm.MarshalJSONPB(&jsonpb.Marshaler{OrigName: true})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func decodeJSON(data json.RawMessage) (interface{}, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (d *Differential) ignored(names []string) bool {
+	return d.ignoredNames[names[len(names)-1]] || d.ignoredPaths[strings.Join(names, ".")]
+}
+
+// diff returns the path of the first difference. Names are the field names of the path, which are matched
+// with the ignored fields, and the path also has the list indexes.
+func (d *Differential) diff(primary, secondary interface{}, names []string, path string, divergence *Divergence) (string, bool) {
+	switch p := primary.(type) {
+	case map[string]interface{}:
+		s, ok := secondary.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(p)+len(s))
+		for key := range p {
+			keys = append(keys, key)
+		}
+		for key := range s {
+			if _, ok := p[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			fieldNames := append(names[:len(names):len(names)], key)
+			if d.ignored(fieldNames) {
+				continue
+			}
+			fieldPath := key
+			if len(path) > 0 {
+				fieldPath = path + "." + key
+			}
+			if field, found := d.diff(p[key], s[key], fieldNames, fieldPath, divergence); found {
+				return field, true
+			}
+		}
+		return "", false
+	case []interface{}:
+		s, ok := secondary.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(p) || i < len(s); i++ {
+			var pItem, sItem interface{}
+			if i < len(p) {
+				pItem = p[i]
+			}
+			if i < len(s) {
+				sItem = s[i]
+			}
+			if field, found := d.diff(pItem, sItem, names, fmt.Sprintf("%s[%d]", path, i), divergence); found {
+				return field, true
+			}
+		}
+		return "", false
+	default:
+		if primary == secondary {
+			return "", false
+		}
+	}
+	divergence.PrimaryValue = primary
+	divergence.SecondaryValue = secondary
+	return path, true
+}
@@ -0,0 +1,701 @@
+package oracle
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+)
+
+// Expr is the compiled invariant expression. The language has the literals (numbers, quoted strings, true,
+// false and null), the field paths (response.items[0].id), the operators ! - * / % + - == != < <= > >= && ||,
+// the parentheses and the functions len(x) and contains(s, sub).
+type Expr struct {
+	source string
+	root   node
+}
+
+// Env resolves the first component of the field paths, e.g. "request" or "response"
+type Env func(name string) (interface{}, bool)
+
+type node interface {
+	eval(env Env) (interface{}, error)
+}
+
+// CompileExpr parses the expression, so the syntax errors are found before fuzzing
+func CompileExpr(source string) (*Expr, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, errors.Errorf("Invalid expression %q: %s", source, err)
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != tokenEOF {
+		err = errors.Errorf("unexpected %q", p.peek().text)
+	}
+	if err != nil {
+		return nil, errors.Errorf("Invalid expression %q: %s", source, err)
+	}
+	return &Expr{source: source, root: root}, nil
+}
+
+func (e *Expr) String() string {
+	return e.source
+}
+
+// EvalBool evaluates the expression, which must give a boolean
+func (e *Expr) EvalBool(env Env) (bool, error) {
+	value, err := e.root.eval(env)
+	if err != nil {
+		return false, errors.Errorf("Failed to evaluate %q: %s", e.source, err)
+	}
+	result, ok := value.(bool)
+	if !ok {
+		return false, errors.Errorf("Expression %q is not a boolean", e.source)
+	}
+	return result, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// Operators are matched longest first
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "%", "(", ")", "[", "]", ".", ","}
+
+func tokenize(source string) ([]token, error) {
+	tokens := make([]token, 0, 16)
+	for i := 0; i < len(source); {
+		c := rune(source[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c):
+			start := i
+			for i < len(source) && (unicode.IsDigit(rune(source[i])) || source[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, source[start:i]})
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(source) && (unicode.IsLetter(rune(source[i])) || unicode.IsDigit(rune(source[i])) || source[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, source[start:i]})
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(source) && source[end] != source[i] {
+				if source[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(source) {
+				return nil, errors.New("unterminated string")
+			}
+			text := source[i : end+1]
+			if c == '\'' {
+				text = `"` + strings.ReplaceAll(text[1:len(text)-1], `"`, `\"`) + `"`
+			}
+			value, err := strconv.Unquote(text)
+			if err != nil {
+				return nil, errors.Errorf("invalid string %s", source[i:end+1])
+			}
+			tokens = append(tokens, token{tokenString, value})
+			i = end + 1
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(source[i:], op) {
+					tokens = append(tokens, token{tokenOp, op})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, errors.Errorf("unexpected character %q", c)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) acceptOp(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expectOp(op string) error {
+	if _, ok := p.acceptOp(op); !ok {
+		if p.peek().kind == tokenEOF {
+			return errors.Errorf("expected %q at the end", op)
+		}
+		return errors.Errorf("expected %q instead of %q", op, p.peek().text)
+	}
+	return nil
+}
+
+func (p *parser) parseBinary(next func() (node, error), ops ...string) (node, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.acceptOp(ops...)
+		if !ok {
+			return left, nil
+		}
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseOr() (node, error) {
+	return p.parseBinary(p.parseAnd, "||")
+}
+
+func (p *parser) parseAnd() (node, error) {
+	return p.parseBinary(p.parseComparison, "&&")
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.acceptOp("==", "!=", "<=", ">=", "<", ">")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryNode{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseSum() (node, error) {
+	return p.parseBinary(p.parseTerm, "+", "-")
+}
+
+func (p *parser) parseTerm() (node, error) {
+	return p.parseBinary(p.parseUnary, "*", "/", "%")
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if op, ok := p.acceptOp("!", "-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: op, operand: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	operand, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOp("."); ok {
+			t := p.next()
+			if t.kind != tokenIdent {
+				return nil, errors.Errorf("expected a field name instead of %q", t.text)
+			}
+			operand = &fieldNode{operand: operand, name: t.text}
+		} else if _, ok := p.acceptOp("["); ok {
+			index, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp("]"); err != nil {
+				return nil, err
+			}
+			operand = &indexNode{operand: operand, index: index}
+		} else {
+			return operand, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		if value, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return &literalNode{value: value}, nil
+		}
+		value, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid number %s", t.text)
+		}
+		return &literalNode{value: value}, nil
+	case tokenString:
+		return &literalNode{value: t.text}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+		if _, ok := p.acceptOp("("); ok {
+			return p.parseCall(t.text)
+		}
+		return &nameNode{name: t.text}, nil
+	case tokenOp:
+		if t.text == "(" {
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expectOp(")")
+		}
+	}
+	if t.kind == tokenEOF {
+		return nil, errors.New("unexpected end")
+	}
+	return nil, errors.Errorf("unexpected %q", t.text)
+}
+
+func (p *parser) parseCall(name string) (node, error) {
+	fn, ok := functions[name]
+	if !ok {
+		return nil, errors.Errorf("unknown function %s", name)
+	}
+	call := &callNode{name: name, fn: fn}
+	if _, ok := p.acceptOp(")"); ok {
+		return call, nil
+	}
+	for {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+		if _, ok := p.acceptOp(")"); ok {
+			return call, nil
+		}
+		if err := p.expectOp(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(env Env) (interface{}, error) {
+	return n.value, nil
+}
+
+type nameNode struct {
+	name string
+}
+
+func (n *nameNode) eval(env Env) (interface{}, error) {
+	value, ok := env(n.name)
+	if !ok {
+		return nil, errors.Errorf("unknown name %s", n.name)
+	}
+	return value, nil
+}
+
+// Fields are resolved by the environment values, so the messages are not tied to this package
+type fieldGetter interface {
+	Field(name string) (interface{}, error)
+}
+
+type fieldNode struct {
+	operand node
+	name    string
+}
+
+func (n *fieldNode) eval(env Env) (interface{}, error) {
+	value, err := n.operand.eval(env)
+	if err != nil || value == nil {
+		// Fields of the unset messages are null
+		return nil, err
+	}
+	switch v := value.(type) {
+	case fieldGetter:
+		return v.Field(n.name)
+	case map[string]interface{}:
+		return v[n.name], nil
+	}
+	return nil, errors.Errorf("%s is not a message", n.name)
+}
+
+type indexNode struct {
+	operand node
+	index   node
+}
+
+func (n *indexNode) eval(env Env) (interface{}, error) {
+	value, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	index, err := n.index.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		i, ok := index.(int64)
+		if !ok {
+			return nil, errors.New("list index is not an integer")
+		}
+		if i < 0 || i >= int64(len(v)) {
+			// Missing elements are null, same as the unset fields
+			return nil, nil
+		}
+		return v[i], nil
+	case map[string]interface{}:
+		return v[fmt.Sprint(index)], nil
+	case nil:
+		return nil, nil
+	}
+	return nil, errors.New("value is not a list or a map")
+}
+
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+func (n *unaryNode) eval(env Env) (interface{}, error) {
+	value, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "!" {
+		b, ok := value.(bool)
+		if !ok {
+			return nil, errors.New("operand of ! is not a boolean")
+		}
+		return !b, nil
+	}
+	switch v := value.(type) {
+	case int64:
+		return -v, nil
+	case float64:
+		return -v, nil
+	}
+	return nil, errors.New("operand of - is not a number")
+}
+
+type binaryNode struct {
+	op    string
+	left  node
+	right node
+}
+
+func (n *binaryNode) eval(env Env) (interface{}, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	// Logical operators are short-circuited, so the right side can rely on the left one
+	if n.op == "&&" || n.op == "||" {
+		lb, ok := left.(bool)
+		if !ok {
+			return nil, errors.Errorf("operand of %s is not a boolean", n.op)
+		}
+		if (n.op == "&&" && !lb) || (n.op == "||" && lb) {
+			return lb, nil
+		}
+		right, err := n.right.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		rb, ok := right.(bool)
+		if !ok {
+			return nil, errors.Errorf("operand of %s is not a boolean", n.op)
+		}
+		return rb, nil
+	}
+
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "<", "<=", ">", ">=":
+		return compare(n.op, left, right)
+	}
+	return arithmetic(n.op, left, right)
+}
+
+type callNode struct {
+	name string
+	fn   func(args []interface{}) (interface{}, error)
+	args []node
+}
+
+func (n *callNode) eval(env Env) (interface{}, error) {
+	args := make([]interface{}, 0, len(n.args))
+	for _, arg := range n.args {
+		value, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+	return n.fn(args)
+}
+
+var functions = map[string]func(args []interface{}) (interface{}, error){
+	"len": func(args []interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, errors.New("len takes one argument")
+		}
+		switch v := args[0].(type) {
+		case string:
+			return int64(len(v)), nil
+		case []interface{}:
+			return int64(len(v)), nil
+		case map[string]interface{}:
+			return int64(len(v)), nil
+		case nil:
+			return int64(0), nil
+		}
+		return nil, errors.New("len argument is not a string, list or map")
+	},
+	"contains": func(args []interface{}) (interface{}, error) {
+		if len(args) != 2 {
+			return nil, errors.New("contains takes two arguments")
+		}
+		switch v := args[0].(type) {
+		case string:
+			sub, ok := args[1].(string)
+			if !ok {
+				return nil, errors.New("contains argument is not a string")
+			}
+			return strings.Contains(v, sub), nil
+		case []interface{}:
+			for _, item := range v {
+				if equal(item, args[1]) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case nil:
+			return false, nil
+		}
+		return nil, errors.New("contains argument is not a string or a list")
+	},
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func equal(left, right interface{}) bool {
+	if li, ok := left.(int64); ok {
+		if ri, ok := right.(int64); ok {
+			return li == ri
+		}
+	}
+	if lf, ok := toFloat(left); ok {
+		rf, ok := toFloat(right)
+		return ok && lf == rf
+	}
+	switch l := left.(type) {
+	case nil, bool, string:
+		return left == right
+	case []interface{}:
+		r, ok := right.([]interface{})
+		if !ok || len(l) != len(r) {
+			return false
+		}
+		for i := range l {
+			if !equal(l[i], r[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		r, ok := right.(map[string]interface{})
+		if !ok || len(l) != len(r) {
+			return false
+		}
+		for key, value := range l {
+			if rv, ok := r[key]; !ok || !equal(value, rv) {
+				return false
+			}
+		}
+		return true
+	case *messageValue:
+		r, ok := right.(*messageValue)
+		if !ok {
+			return false
+		}
+		if l.msg == nil || r.msg == nil {
+			return l.msg == r.msg
+		}
+		return dynamic.Equal(l.msg, r.msg)
+	}
+	return fmt.Sprint(left) == fmt.Sprint(right)
+}
+
+func compare(op string, left, right interface{}) (bool, error) {
+	var cmp int
+	if ls, ok := left.(string); ok {
+		rs, ok := right.(string)
+		if !ok {
+			return false, errors.Errorf("operands of %s are not comparable", op)
+		}
+		cmp = strings.Compare(ls, rs)
+	} else if li, ok := left.(int64); ok {
+		ri, ok := right.(int64)
+		if !ok {
+			return compareFloats(op, left, right)
+		}
+		switch {
+		case li < ri:
+			cmp = -1
+		case li > ri:
+			cmp = 1
+		}
+	} else {
+		return compareFloats(op, left, right)
+	}
+	return compareResult(op, cmp), nil
+}
+
+func compareFloats(op string, left, right interface{}) (bool, error) {
+	lf, lok := toFloat(left)
+	rf, rok := toFloat(right)
+	if !lok || !rok {
+		return false, errors.Errorf("operands of %s are not comparable", op)
+	}
+	cmp := 0
+	switch {
+	case lf < rf:
+		cmp = -1
+	case lf > rf:
+		cmp = 1
+	}
+	return compareResult(op, cmp), nil
+}
+
+func compareResult(op string, cmp int) bool {
+	switch op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+func arithmetic(op string, left, right interface{}) (interface{}, error) {
+	if ls, ok := left.(string); ok && op == "+" {
+		if rs, ok := right.(string); ok {
+			return ls + rs, nil
+		}
+	}
+
+	li, lok := left.(int64)
+	ri, rok := right.(int64)
+	if lok && rok {
+		switch op {
+		case "+":
+			return li + ri, nil
+		case "-":
+			return li - ri, nil
+		case "*":
+			return li * ri, nil
+		case "/", "%":
+			if ri == 0 {
+				return nil, errors.New("division by zero")
+			}
+			if op == "/" {
+				return li / ri, nil
+			}
+			return li % ri, nil
+		}
+	}
+
+	lf, lok := toFloat(left)
+	rf, rok := toFloat(right)
+	if !lok || !rok {
+		return nil, errors.Errorf("operands of %s are not numbers", op)
+	}
+	switch op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		return lf / rf, nil
+	}
+	return math.Mod(lf, rf), nil
+}
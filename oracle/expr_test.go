@@ -0,0 +1,161 @@
+package oracle
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompileExprErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"", "unexpected end"},
+		{"1 +", "unexpected end"},
+		{"(1 + 2", `expected ")" at the end`},
+		{"response.items[0", `expected "]" at the end`},
+		{"1 2", `unexpected "2"`},
+		{"response.", "expected a field name"},
+		{`"abc`, "unterminated string"},
+		{"1 # 2", "unexpected character"},
+		{"1.2.3 == 1", "invalid number"},
+		{"size(response)", "unknown function size"},
+		{"contains(response.name 'a')", `expected "," instead of "a"`},
+	}
+	for _, test := range tests {
+		_, err := CompileExpr(test.source)
+		if err == nil {
+			t.Errorf("CompileExpr(%q) succeeded, want %q", test.source, test.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("CompileExpr(%q) = %q, want %q", test.source, err, test.err)
+		}
+	}
+}
+
+func TestEvalBool(t *testing.T) {
+	env := func(name string) (interface{}, bool) {
+		switch name {
+		case "request":
+			return map[string]interface{}{
+				"name":  "alice",
+				"count": int64(3),
+				"ratio": 0.5,
+				"tags":  []interface{}{"a", "b"},
+			}, true
+		case "response":
+			return map[string]interface{}{
+				"name":  "alice",
+				"count": int64(3),
+				"items": []interface{}{
+					map[string]interface{}{"id": int64(1)},
+					map[string]interface{}{"id": int64(2)},
+				},
+				"labels": map[string]interface{}{"env": "test"},
+				"copy":   map[string]interface{}{"env": "test"},
+				"other":  map[string]interface{}{"env": "prod"},
+			}, true
+		}
+		return nil, false
+	}
+
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{"1 + 2 * 3 == 7", true},
+		{"(1 + 2) * 3 == 9", true},
+		{"10 - 4 - 3 == 3", true},
+		{"7 / 2 == 3", true},
+		{"7 % 3 == 1", true},
+		{"-2 + 5 == 3", true},
+		{"!(1 < 2)", false},
+		{"7.0 / 2 == 3.5", true},
+		{"request.count == 3.0", true},
+		{"request.ratio < request.count", true},
+		{"request.count >= 3 && request.count <= 3", true},
+		{"'abc' < 'abd'", true},
+		{`"a" + 'b' == "ab"`, true},
+		{`'it"s' == "it\"s"`, true},
+		{"request.name == response.name", true},
+		{"request.name != 'bob'", true},
+		{"len(response.items) == 2", true},
+		{"len(request.name) == 5", true},
+		{"len(response.missing) == 0", true},
+		{"response.items[1].id == 2", true},
+		{"response.items[5] == null", true},
+		{"response.items[-1].id == null", true},
+		{"response.missing.field == null", true},
+		{"response.labels['env'] == 'test'", true},
+		{"response.labels == response.copy", true},
+		{"response.labels == response.other", false},
+		{"request.tags == request.tags", true},
+		{"contains(request.tags, 'b')", true},
+		{"contains(request.tags, 'c')", false},
+		{"contains(request.name, 'lic')", true},
+		{"contains(response.missing, 'a')", false},
+		{"false && 1 / 0 == 1", false},
+		{"true || unknown.field", true},
+		{"null == null", true},
+		{"1 == '1'", false},
+	}
+	for _, test := range tests {
+		expr, err := CompileExpr(test.source)
+		if err != nil {
+			t.Errorf("CompileExpr(%q) failed: %s", test.source, err)
+			continue
+		}
+		got, err := expr.EvalBool(env)
+		if err != nil {
+			t.Errorf("EvalBool(%q) failed: %s", test.source, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("EvalBool(%q) = %t, want %t", test.source, got, test.want)
+		}
+	}
+}
+
+func TestEvalBoolErrors(t *testing.T) {
+	env := func(name string) (interface{}, bool) {
+		if name == "response" {
+			return map[string]interface{}{"name": "alice", "count": int64(3)}, true
+		}
+		return nil, false
+	}
+
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"response.count / 0 == 1", "division by zero"},
+		{"response.count % 0 == 1", "division by zero"},
+		{"response.count + 1", "is not a boolean"},
+		{"unknown.field == 1", "unknown name unknown"},
+		{"response.name < 1", "not comparable"},
+		{"response.name - 1 == 0", "not numbers"},
+		{"!response.count", "operand of ! is not a boolean"},
+		{"-response.name == 0", "operand of - is not a number"},
+		{"response.count && true", "operand of && is not a boolean"},
+		{"response.name.first == null", "first is not a message"},
+		{"response.count[0] == null", "not a list or a map"},
+		{"len(response.count) == 0", "len argument"},
+		{"len() == 0", "len takes one argument"},
+	}
+	for _, test := range tests {
+		expr, err := CompileExpr(test.source)
+		if err != nil {
+			t.Errorf("CompileExpr(%q) failed: %s", test.source, err)
+			continue
+		}
+		_, err = expr.EvalBool(env)
+		if err == nil {
+			t.Errorf("EvalBool(%q) succeeded, want %q", test.source, test.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("EvalBool(%q) = %q, want %q", test.source, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package oracle
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mrand "math/rand"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/lukjok/gipcfuzz/config"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Kinds of the injected canaries
+const (
+	InjectionCommand   = "command"
+	InjectionTraversal = "traversal"
+	InjectionSSRF      = "ssrf"
+)
+
+const (
+	defaultInjectionProbability = 0.1
+	// Canaries of the older injections are forgotten, so the late hits cannot be mistaken for the new ones
+	maxCanaries = 1024
+	// Prefixes of the canary files tell the created files from the read ones
+	commandPrefix   = "c"
+	traversalPrefix = "r"
+)
+
+// Canary is the payload injected into a single field of the message
+type Canary struct {
+	Token   string
+	Kind    string
+	Method  string
+	Field   string
+	Payload string
+	Message []byte
+}
+
+// Bucket is the field path without the list indexes, so the same field of any element is one finding
+func (c *Canary) Bucket() string {
+	return listIndex.ReplaceAllString(c.Field, "[]")
+}
+
+// InjectionHit is the canary which has taken effect
+type InjectionHit struct {
+	Canary   *Canary
+	Evidence string
+}
+
+// Injection injects the canary payloads into the string fields and detects when they take effect: the files
+// created by the shell commands, the canary files read by the target and the callbacks to the local listener
+type Injection struct {
+	Directory   string
+	Address     string
+	probability float64
+	rand        *mrand.Rand
+	// Reads are only detected where the file system can be watched
+	watchReads bool
+	stopWatch  func()
+	server     *http.Server
+
+	mu       sync.Mutex
+	canaries map[string]*Canary
+	order    []string
+	hit      map[string]bool
+	hits     []InjectionHit
+	close    sync.Once
+}
+
+func NewInjection(cfg config.InjectionOracle) (*Injection, error) {
+	inj := &Injection{
+		probability: cfg.Probability,
+		rand:        mrand.New(mrand.NewSource(time.Now().UnixNano())),
+		canaries:    map[string]*Canary{},
+		hit:         map[string]bool{},
+	}
+	if inj.probability <= 0 {
+		inj.probability = defaultInjectionProbability
+	}
+
+	// Canaries always get a directory of their own, since it is emptied and removed. The configured directory
+	// only tells where it is created, so the files of the user are never touched.
+	parent := cfg.Directory
+	if len(parent) > 0 {
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			return nil, errors.Errorf("Failed to create the canary directory: %s", err)
+		}
+	}
+	directory, err := os.MkdirTemp(parent, "gipcfuzz-canary")
+	if err == nil {
+		inj.Directory, err = filepath.Abs(directory)
+	}
+	if err != nil {
+		return nil, errors.Errorf("Failed to create the canary directory: %s", err)
+	}
+	// Target may run as another user and still has to create the canary files
+	if err := os.Chmod(inj.Directory, 0777); err != nil {
+		return nil, errors.Errorf("Failed to create the canary directory: %s", err)
+	}
+
+	listen := cfg.Listen
+	if len(listen) == 0 {
+		listen = "127.0.0.1:0"
+	}
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, errors.Errorf("Failed to start the canary listener: %s", err)
+	}
+	inj.Address = listener.Addr().String()
+	inj.server = &http.Server{Handler: http.HandlerFunc(inj.handleCallback)}
+	go inj.server.Serve(listener)
+
+	if inj.stopWatch, err = watchReads(inj.Directory, inj.handleRead); err == nil {
+		inj.watchReads = true
+	}
+	return inj, nil
+}
+
+// WatchesReads tells if the path traversal canaries are injected, since their reads cannot be detected everywhere
+func (inj *Injection) WatchesReads() bool {
+	return inj.watchReads
+}
+
+// Inject replaces a random string field of the message with a canary payload now and then. The message
+// is changed in place and the injected canary is returned, or nil if nothing was injected.
+func (inj *Injection) Inject(method string, msg *dynamic.Message) (*Canary, error) {
+	if inj.rand.Float64() >= inj.probability {
+		return nil, nil
+	}
+	fields := stringFields(msg, "")
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	field := fields[inj.rand.Intn(len(fields))]
+
+	kinds := []string{InjectionCommand, InjectionSSRF}
+	if inj.watchReads {
+		kinds = append(kinds, InjectionTraversal)
+	}
+	canary := &Canary{
+		Token:  newToken(),
+		Kind:   kinds[inj.rand.Intn(len(kinds))],
+		Method: method,
+		Field:  field.path,
+	}
+	payload, err := inj.payload(canary, field.get())
+	if err != nil {
+		return nil, err
+	}
+	canary.Payload = payload
+	if err := field.set(payload); err != nil {
+		return nil, err
+	}
+	if canary.Message, err = msg.Marshal(); err != nil {
+		return nil, err
+	}
+	inj.register(canary)
+	return canary, nil
+}
+
+func (inj *Injection) payload(canary *Canary, original string) (string, error) {
+	switch canary.Kind {
+	case InjectionCommand:
+		file := filepath.Join(inj.Directory, commandPrefix+canary.Token)
+		payloads := []string{";touch %s;", "$(touch %s)", "`touch %s`", "|touch %s", "&& touch %s", "\ntouch %s\n"}
+		if runtime.GOOS == "windows" {
+			payloads = []string{"& type nul > %s &", "| type nul > %s", "&& type nul > %s", "\r\ntype nul > %s\r\n"}
+		}
+		// Original value is kept in front, so the command still gets past the validation of the argument
+		return original + fmt.Sprintf(payloads[inj.rand.Intn(len(payloads))], file), nil
+	case InjectionTraversal:
+		file := filepath.Join(inj.Directory, traversalPrefix+canary.Token)
+		if err := os.WriteFile(file, []byte(canary.Token), 0644); err != nil {
+			return "", err
+		}
+		if inj.rand.Intn(2) == 0 {
+			return file, nil
+		}
+		// Enough parents to reach the root from any working directory
+		up := strings.Repeat(".."+string(filepath.Separator), 16)
+		return up + strings.TrimLeft(strings.TrimPrefix(file, filepath.VolumeName(file)), string(filepath.Separator)), nil
+	}
+	hosts := []string{inj.Address, strings.Replace(inj.Address, "127.0.0.1", "localhost", 1)}
+	return fmt.Sprintf("http://%s/%s", hosts[inj.rand.Intn(len(hosts))], canary.Token), nil
+}
+
+func (inj *Injection) register(canary *Canary) {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+	inj.canaries[canary.Token] = canary
+	inj.order = append(inj.order, canary.Token)
+	if len(inj.order) <= maxCanaries {
+		return
+	}
+	expired := inj.canaries[inj.order[0]]
+	delete(inj.canaries, expired.Token)
+	delete(inj.hit, expired.Token)
+	inj.order = inj.order[1:]
+	if expired.Kind == InjectionTraversal {
+		os.Remove(filepath.Join(inj.Directory, traversalPrefix+expired.Token))
+	}
+}
+
+// Hits returns the canaries which have taken effect since the previous call. Each canary is reported once.
+func (inj *Injection) Hits() []InjectionHit {
+	// Created files are only looked for here, since the file system cannot be watched everywhere
+	if entries, err := os.ReadDir(inj.Directory); err == nil {
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), commandPrefix) {
+				path := filepath.Join(inj.Directory, entry.Name())
+				inj.addHit(strings.TrimPrefix(entry.Name(), commandPrefix), fmt.Sprintf("File %s was created", path))
+				os.Remove(path)
+			}
+		}
+	}
+
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+	hits := inj.hits
+	inj.hits = nil
+	return hits
+}
+
+func (inj *Injection) addHit(token, evidence string) {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+	canary, ok := inj.canaries[token]
+	if !ok || inj.hit[token] {
+		return
+	}
+	inj.hit[token] = true
+	inj.hits = append(inj.hits, InjectionHit{Canary: canary, Evidence: evidence})
+}
+
+func (inj *Injection) handleCallback(w http.ResponseWriter, r *http.Request) {
+	for _, part := range strings.Split(r.URL.Path, "/") {
+		if len(part) > 0 {
+			inj.addHit(part, fmt.Sprintf("%s %s from %s", r.Method, r.URL.RequestURI(), r.RemoteAddr))
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (inj *Injection) handleRead(name string) {
+	if strings.HasPrefix(name, traversalPrefix) {
+		inj.addHit(strings.TrimPrefix(name, traversalPrefix), fmt.Sprintf("File %s was read", filepath.Join(inj.Directory, name)))
+	}
+}
+
+// Close stops the listener and the watch, and removes the canary files
+func (inj *Injection) Close() {
+	inj.close.Do(func() {
+		inj.server.Close()
+		if inj.stopWatch != nil {
+			inj.stopWatch()
+		}
+		os.RemoveAll(inj.Directory)
+	})
+}
+
+func newToken() string {
+	buf := make([]byte, 6)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// stringField is the string field of the message or a single element of the repeated one
+type stringField struct {
+	path string
+	get  func() string
+	set  func(value string) error
+}
+
+// stringFields lists the string fields of the message and the set messages in it
+func stringFields(msg *dynamic.Message, prefix string) []stringField {
+	fields := make([]stringField, 0, 4)
+	for _, fd := range msg.GetMessageDescriptor().GetFields() {
+		fd := fd
+		path := prefix + fd.GetName()
+		switch {
+		case fd.IsMap():
+			continue
+		case fd.GetType() == descriptorpb.FieldDescriptorProto_TYPE_STRING && fd.IsRepeated():
+			for i := 0; i < msg.FieldLength(fd); i++ {
+				i := i
+				fields = append(fields, stringField{
+					path: fmt.Sprintf("%s[%d]", path, i),
+					get:  func() string { value, _ := msg.GetRepeatedField(fd, i).(string); return value },
+					set:  func(value string) error { return msg.TrySetRepeatedField(fd, i, value) },
+				})
+			}
+		case fd.GetType() == descriptorpb.FieldDescriptorProto_TYPE_STRING:
+			fields = append(fields, stringField{
+				path: path,
+				get:  func() string { value, _ := msg.GetField(fd).(string); return value },
+				set:  func(value string) error { return msg.TrySetField(fd, value) },
+			})
+		case fd.GetType() == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE && fd.IsRepeated():
+			for i := 0; i < msg.FieldLength(fd); i++ {
+				if nested, ok := msg.GetRepeatedField(fd, i).(*dynamic.Message); ok {
+					fields = append(fields, stringFields(nested, fmt.Sprintf("%s[%d].", path, i))...)
+				}
+			}
+		case fd.GetType() == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE && msg.HasField(fd):
+			if nested, ok := msg.GetField(fd).(*dynamic.Message); ok {
+				fields = append(fields, stringFields(nested, path+".")...)
+			}
+		}
+	}
+	return fields
+}
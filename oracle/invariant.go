@@ -0,0 +1,152 @@
+package oracle
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// InvariantViolation is the invariant which did not hold for the call
+type InvariantViolation struct {
+	Expression string
+}
+
+// Invariants checks the user expressions over the request and the response of the methods
+type Invariants struct {
+	methods map[string][]*Expr
+	// Evaluation errors are reported once for each expression
+	reported map[*Expr]bool
+}
+
+func NewInvariants(methods map[string][]string) (*Invariants, error) {
+	inv := &Invariants{
+		methods:  make(map[string][]*Expr, len(methods)),
+		reported: map[*Expr]bool{},
+	}
+	for method, sources := range methods {
+		for _, source := range sources {
+			expr, err := CompileExpr(source)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "Invariant of %s", method)
+			}
+			inv.methods[method] = append(inv.methods[method], expr)
+		}
+	}
+	return inv, nil
+}
+
+// Has tells if the method has any invariants, so the messages are not decoded for nothing
+func (inv *Invariants) Has(method string) bool {
+	return len(inv.methods[method]) > 0
+}
+
+// Check evaluates the invariants of the method. Evaluation errors (e.g. an index past the end of the fuzzed
+// list) only skip the invariant for this call. They are returned the first time for each invariant, so a wrong
+// invariant is noticed without flooding the log.
+func (inv *Invariants) Check(method string, request, response *dynamic.Message) ([]InvariantViolation, []error) {
+	env := func(name string) (interface{}, bool) {
+		switch name {
+		case "request":
+			return &messageValue{request}, true
+		case "response":
+			return &messageValue{response}, true
+		}
+		return nil, false
+	}
+
+	var violations []InvariantViolation
+	var errs []error
+	for _, expr := range inv.methods[method] {
+		holds, err := expr.EvalBool(env)
+		if err != nil {
+			if !inv.reported[expr] {
+				inv.reported[expr] = true
+				errs = append(errs, errors.WithMessagef(err, "Invariant %s of %s is skipped for the calls where it fails", expr, method))
+			}
+			continue
+		}
+		if !holds {
+			violations = append(violations, InvariantViolation{Expression: expr.String()})
+		}
+	}
+	return violations, errs
+}
+
+// messageValue gives the fields of the decoded message to the expressions
+type messageValue struct {
+	msg *dynamic.Message
+}
+
+func (m *messageValue) Field(name string) (interface{}, error) {
+	md := m.msg.GetMessageDescriptor()
+	fd := md.FindFieldByName(name)
+	if fd == nil {
+		fd = md.FindFieldByJSONName(name)
+	}
+	if fd == nil {
+		return nil, errors.Errorf("%s has no field %s", md.GetName(), name)
+	}
+	return convertField(fd, m.msg.GetField(fd)), nil
+}
+
+// convertField turns the field value into one of the expression types: null, bool, int64, float64, string,
+// list, map or message. Enums are compared by their names.
+func convertField(fd *desc.FieldDescriptor, value interface{}) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			list = append(list, convertScalar(fd, item))
+		}
+		return list
+	case map[interface{}]interface{}:
+		valueFd := fd.GetMapValueType()
+		entries := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			entries[fmt.Sprint(key)] = convertScalar(valueFd, item)
+		}
+		return entries
+	}
+	return convertScalar(fd, value)
+}
+
+func convertScalar(fd *desc.FieldDescriptor, value interface{}) interface{} {
+	switch v := value.(type) {
+	case *dynamic.Message:
+		if v == nil {
+			return nil
+		}
+		return &messageValue{v}
+	case proto.Message:
+		// Well known types can be decoded into the generated messages
+		if dm, err := dynamic.AsDynamicMessage(v); err == nil {
+			return &messageValue{dm}
+		}
+		return nil
+	case int32:
+		if fd.GetType() == descriptorpb.FieldDescriptorProto_TYPE_ENUM {
+			if enumValue := fd.GetEnumType().FindValueByNumber(v); enumValue != nil {
+				return enumValue.GetName()
+			}
+		}
+		return int64(v)
+	case int64:
+		return v
+	case uint32:
+		return int64(v)
+	case uint64:
+		if v > 1<<63-1 {
+			return float64(v)
+		}
+		return int64(v)
+	case float32:
+		return float64(v)
+	case []byte:
+		return string(v)
+	}
+	return value
+}
@@ -0,0 +1,95 @@
+package oracle
+
+import (
+	"sort"
+	"time"
+
+	"github.com/lukjok/gipcfuzz/config"
+)
+
+const (
+	// Below the default timeout multiplier, so the slow calls are found before they time out
+	defaultOutlierFactor = 3.0
+	defaultMinSamples    = 20
+	defaultMinLatency    = 10 * time.Millisecond
+	// Only the recent calls are kept, so the distribution follows the changes of the target
+	latencyWindowSize = 256
+)
+
+// LatencyObservation is the latency of the call compared with the other calls of the method
+type LatencyObservation struct {
+	Outlier bool
+	Median  time.Duration
+	// NewMax is set when the call is the slowest of the method so far
+	NewMax bool
+}
+
+// Latency keeps the latency distribution of each method and finds the calls which are much slower than usual
+type Latency struct {
+	factor     float64
+	minSamples int
+	minLatency time.Duration
+	methods    map[string]*latencyStats
+}
+
+type latencyStats struct {
+	window []time.Duration
+	next   int
+	max    time.Duration
+}
+
+func NewLatency(cfg config.LatencyOracle) *Latency {
+	l := &Latency{
+		factor:     cfg.OutlierFactor,
+		minSamples: cfg.MinSamples,
+		minLatency: time.Duration(cfg.MinLatency) * time.Millisecond,
+		methods:    map[string]*latencyStats{},
+	}
+	if l.factor <= 1 {
+		l.factor = defaultOutlierFactor
+	}
+	if l.minSamples == 0 {
+		l.minSamples = defaultMinSamples
+	}
+	if l.minLatency == 0 {
+		l.minLatency = defaultMinLatency
+	}
+	return l
+}
+
+// Observe adds the latency of the call to the distribution of the method. Outliers are not added,
+// so a series of slow inputs does not shift the median.
+func (l *Latency) Observe(method string, latency time.Duration) LatencyObservation {
+	stats, ok := l.methods[method]
+	if !ok {
+		stats = &latencyStats{window: make([]time.Duration, 0, latencyWindowSize)}
+		l.methods[method] = stats
+	}
+
+	obs := LatencyObservation{Median: stats.median()}
+	if latency > stats.max {
+		stats.max = latency
+		obs.NewMax = true
+	}
+	if len(stats.window) >= l.minSamples && latency >= l.minLatency && float64(latency) > float64(obs.Median)*l.factor {
+		obs.Outlier = true
+		return obs
+	}
+
+	if len(stats.window) < latencyWindowSize {
+		stats.window = append(stats.window, latency)
+	} else {
+		stats.window[stats.next] = latency
+		stats.next = (stats.next + 1) % latencyWindowSize
+	}
+	return obs
+}
+
+func (s *latencyStats) median() time.Duration {
+	if len(s.window) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration{}, s.window...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
@@ -0,0 +1,213 @@
+package oracle
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lukjok/gipcfuzz/config"
+	"github.com/lukjok/gipcfuzz/watcher"
+)
+
+// Resources which are checked by the resource oracle
+const (
+	ResourceRSS       = "rss"
+	ResourceCPU       = "cpu"
+	ResourceThreads   = "threads"
+	ResourceOpenFiles = "openFiles"
+)
+
+const (
+	defaultRSSJump          = 64 << 20
+	defaultCPUJump          = time.Second
+	defaultThreadJump       = 16
+	defaultOpenFileJump     = 64
+	defaultRSSLeakCandidate = 1 << 20
+	defaultRepeatRuns       = 5
+	defaultLeakWindow       = 100
+	// Memory has to grow in this many windows in a row, so the caches which fill up once are not reported
+	minLeakWindows = 3
+)
+
+// ResourceViolation is the resource which has grown past its threshold or grows with every execution
+type ResourceViolation struct {
+	Resource    string
+	Before      int64
+	After       int64
+	Description string
+}
+
+// Resources finds the inputs which make the target use much more resources or leak them
+type Resources struct {
+	rssJump          uint64
+	cpuJump          time.Duration
+	threadJump       int
+	openFileJump     int
+	rssLeakCandidate uint64
+	// RepeatRuns is the number of the repeated executions which confirm the leak
+	RepeatRuns int
+	leakWindow int
+	// Memory growth of every method in the life of the current process
+	trendPid int
+	trends   map[string]*leakTrend
+}
+
+// leakTrend is the memory growth during the calls of a single method, summed in windows of calls
+type leakTrend struct {
+	windowStart  uint64
+	windowGrowth int64
+	windowCalls  int
+	streak       int
+	streakStart  uint64
+	streakGrowth int64
+	reported     bool
+}
+
+func NewResources(cfg config.ResourceOracle) *Resources {
+	r := &Resources{
+		rssJump:          cfg.RSSJump,
+		cpuJump:          time.Duration(cfg.CPUJump) * time.Millisecond,
+		threadJump:       cfg.ThreadJump,
+		openFileJump:     cfg.OpenFileJump,
+		rssLeakCandidate: cfg.RSSLeakCandidate,
+		RepeatRuns:       cfg.RepeatRuns,
+		leakWindow:       cfg.LeakWindow,
+	}
+	if r.rssJump == 0 {
+		r.rssJump = defaultRSSJump
+	}
+	if r.cpuJump == 0 {
+		r.cpuJump = defaultCPUJump
+	}
+	if r.threadJump == 0 {
+		r.threadJump = defaultThreadJump
+	}
+	if r.openFileJump == 0 {
+		r.openFileJump = defaultOpenFileJump
+	}
+	if r.rssLeakCandidate == 0 {
+		r.rssLeakCandidate = defaultRSSLeakCandidate
+	}
+	if r.RepeatRuns < 2 {
+		r.RepeatRuns = defaultRepeatRuns
+	}
+	if r.leakWindow < 1 {
+		r.leakWindow = defaultLeakWindow
+	}
+	return r
+}
+
+// CheckTrend adds the memory growth of the call to the trend of its method. Slow leaks of a few kilobytes per
+// call are lost in the noise of a single call, so the growth is summed in windows of calls. Leak is reported
+// once the memory has grown in several windows in a row and by rssLeakCandidate bytes in total. Trends start
+// over when the process is restarted, and each method is reported once per process.
+func (r *Resources) CheckTrend(pid int, method string, before, after watcher.ResourceUsage) *ResourceViolation {
+	if pid != r.trendPid || r.trends == nil {
+		r.trendPid = pid
+		r.trends = map[string]*leakTrend{}
+	}
+	trend, ok := r.trends[method]
+	if !ok {
+		trend = &leakTrend{}
+		r.trends[method] = trend
+	}
+	if trend.windowCalls == 0 {
+		trend.windowStart = before.RSS
+	}
+	trend.windowGrowth += int64(after.RSS) - int64(before.RSS)
+	trend.windowCalls++
+	if trend.windowCalls < r.leakWindow {
+		return nil
+	}
+
+	if trend.windowGrowth > 0 {
+		if trend.streak == 0 {
+			trend.streakStart = trend.windowStart
+		}
+		trend.streak++
+		trend.streakGrowth += trend.windowGrowth
+	} else {
+		trend.streak, trend.streakGrowth = 0, 0
+	}
+	trend.windowGrowth, trend.windowCalls = 0, 0
+
+	if trend.reported || trend.streak < minLeakWindows || trend.streakGrowth < int64(r.rssLeakCandidate) {
+		return nil
+	}
+	trend.reported = true
+	calls := int64(trend.streak * r.leakWindow)
+	return &ResourceViolation{
+		Resource: ResourceRSS,
+		Before:   int64(trend.streakStart),
+		After:    int64(after.RSS),
+		Description: fmt.Sprintf("Resident memory grew by %d bytes during %d calls of the method, %d bytes per call",
+			trend.streakGrowth, calls, trend.streakGrowth/calls),
+	}
+}
+
+// CheckJump returns the resource which has grown past its threshold during a single execution
+func (r *Resources) CheckJump(before, after watcher.ResourceUsage) *ResourceViolation {
+	switch {
+	case after.RSS > before.RSS+r.rssJump:
+		return newViolation(ResourceRSS, int64(before.RSS), int64(after.RSS), "Resident memory grew by %d bytes")
+	case after.CPUTime > before.CPUTime+r.cpuJump:
+		return newViolation(ResourceCPU, before.CPUTime.Milliseconds(), after.CPUTime.Milliseconds(), "Call used %d ms of CPU time")
+	case after.Threads > before.Threads+r.threadJump:
+		return newViolation(ResourceThreads, int64(before.Threads), int64(after.Threads), "Thread count grew by %d")
+	case after.OpenFiles > before.OpenFiles+r.openFileJump:
+		return newViolation(ResourceOpenFiles, int64(before.OpenFiles), int64(after.OpenFiles), "Open descriptor count grew by %d")
+	}
+	return nil
+}
+
+// IsLeakCandidate tells if the execution has left more resources behind, so it has to be repeated to confirm the leak.
+// Memory is only checked past the candidate size, since the allocators grow the heap in chunks.
+func (r *Resources) IsLeakCandidate(before, after watcher.ResourceUsage) bool {
+	return after.Threads > before.Threads ||
+		after.OpenFiles > before.OpenFiles ||
+		after.RSS > before.RSS+r.rssLeakCandidate
+}
+
+// CheckGrowth returns the resource which has grown with the repeated executions of the same input. Threads and
+// descriptors have to grow every time. Memory must not shrink and has to grow at least in half of the executions.
+func (r *Resources) CheckGrowth(samples []watcher.ResourceUsage) *ResourceViolation {
+	if len(samples) < 2 {
+		return nil
+	}
+	first, last := samples[0], samples[len(samples)-1]
+	runs := len(samples) - 1
+
+	threadGrowth, fileGrowth, rssGrowth := 0, 0, 0
+	rssShrinks := false
+	for i := 1; i < len(samples); i++ {
+		if samples[i].Threads > samples[i-1].Threads {
+			threadGrowth++
+		}
+		if samples[i].OpenFiles > samples[i-1].OpenFiles {
+			fileGrowth++
+		}
+		if samples[i].RSS > samples[i-1].RSS {
+			rssGrowth++
+		} else if samples[i].RSS < samples[i-1].RSS {
+			rssShrinks = true
+		}
+	}
+
+	switch {
+	case threadGrowth == runs:
+		return newViolation(ResourceThreads, int64(first.Threads), int64(last.Threads), "Thread count grew by %d with the repeated executions")
+	case fileGrowth == runs:
+		return newViolation(ResourceOpenFiles, int64(first.OpenFiles), int64(last.OpenFiles), "Open descriptor count grew by %d with the repeated executions")
+	case !rssShrinks && rssGrowth*2 >= runs:
+		return newViolation(ResourceRSS, int64(first.RSS), int64(last.RSS), "Resident memory grew by %d bytes with the repeated executions")
+	}
+	return nil
+}
+
+func newViolation(resource string, before, after int64, format string) *ResourceViolation {
+	return &ResourceViolation{
+		Resource:    resource,
+		Before:      before,
+		After:       after,
+		Description: fmt.Sprintf(format, after-before),
+	}
+}
@@ -0,0 +1,181 @@
+package oracle
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/lukjok/gipcfuzz/config"
+	"github.com/pkg/errors"
+	_ "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// Codes which mostly mean that an unhandled exception was caught by the gRPC runtime
+var defaultInterestingCodes = []string{"INTERNAL", "UNKNOWN", "DATA_LOSS"}
+
+// Error messages which reveal the stack or the files of the server
+var defaultLeakPatterns = []string{
+	// Java and .NET frames
+	`\bat [\w$.<>]+\([\w$]+\.java:\d+\)`,
+	`\bat [\w.<>` + "`" + `]+\(.*\) in .+:line \d+`,
+	// Python traceback
+	`Traceback \(most recent call last\)`,
+	`File "[^"]+", line \d+`,
+	// Go panic
+	`goroutine \d+ \[`,
+	// Native backtraces
+	`#\d+\s+0x[0-9a-fA-F]+ in `,
+	// Source files with the line
+	`[\w-]+\.(?:c|cc|cpp|cxx|h|hpp|go|py|java|rs|cs|js|ts|rb|php):\d+`,
+	// Absolute paths
+	`(?:^|[\s'"(=:])/(?:home|usr|opt|var|etc|srv|tmp|root|app|src|build)/[\w./-]+`,
+	`\b[A-Za-z]:\\[\w\\. -]+`,
+}
+
+// StatusViolation is the error status which is interesting for the method or reveals the internals of the server
+type StatusViolation struct {
+	Code    codes.Code
+	Message string
+	// Details are the decoded status details, e.g. ErrorInfo or DebugInfo, in the JSON form
+	Details []json.RawMessage
+	// Leak is the part of the message or the details which has matched the leak pattern
+	Leak   string
+	Reason string
+}
+
+// Status finds the error statuses which are not the normal answers of the server
+type Status struct {
+	codes    map[codes.Code]bool
+	methods  map[string]map[codes.Code]bool
+	patterns []*regexp.Regexp
+}
+
+func NewStatus(cfg config.StatusOracle) (*Status, error) {
+	s := &Status{methods: map[string]map[codes.Code]bool{}}
+
+	var err error
+	codeNames := cfg.Codes
+	if len(codeNames) == 0 {
+		codeNames = defaultInterestingCodes
+	}
+	if s.codes, err = parseCodes(codeNames); err != nil {
+		return nil, err
+	}
+	for method, names := range cfg.Methods {
+		if s.methods[method], err = parseCodes(names); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, pattern := range append(append([]string{}, defaultLeakPatterns...), cfg.LeakPatterns...) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Errorf("Invalid leak pattern %s: %s", pattern, err)
+		}
+		s.patterns = append(s.patterns, re)
+	}
+	return s, nil
+}
+
+// Check returns the violation if the error of the call has an interesting status code or its message
+// or details contain a stack trace or a file path
+func (s *Status) Check(method string, err error) *StatusViolation {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() == codes.OK {
+		return nil
+	}
+
+	violation := &StatusViolation{
+		Code:    st.Code(),
+		Message: st.Message(),
+	}
+	texts := []string{st.Message()}
+	for _, detail := range st.Proto().GetDetails() {
+		encoded, detailTexts := decodeDetail(detail)
+		violation.Details = append(violation.Details, encoded)
+		texts = append(texts, detailTexts...)
+	}
+
+	interesting := s.codes
+	if methodCodes, ok := s.methods[method]; ok {
+		interesting = methodCodes
+	}
+	for _, re := range s.patterns {
+		for _, text := range texts {
+			if leak := re.FindString(text); len(leak) > 0 {
+				violation.Leak = strings.TrimSpace(leak)
+				violation.Reason = "leak"
+				return violation
+			}
+		}
+	}
+	if interesting[st.Code()] {
+		violation.Reason = "code"
+		return violation
+	}
+	return nil
+}
+
+// decodeDetail returns the detail in the JSON form and its strings, e.g. the stack entries of DebugInfo.
+// Details of the unknown types are kept as the type URL and the raw bytes.
+func decodeDetail(detail *anypb.Any) (json.RawMessage, []string) {
+	if msg, err := detail.UnmarshalNew(); err == nil {
+		if encoded, err := protojson.Marshal(detail); err == nil {
+			return encoded, messageStrings(msg.ProtoReflect(), nil)
+		}
+	}
+	encoded, _ := json.Marshal(struct {
+		Type  string `json:"@type"`
+		Value []byte `json:"value"`
+	}{detail.GetTypeUrl(), detail.GetValue()})
+	return encoded, nil
+}
+
+// messageStrings collects the values of all string fields of the message and its nested messages
+func messageStrings(msg protoreflect.Message, texts []string) []string {
+	addValue := func(fd protoreflect.FieldDescriptor, v protoreflect.Value) {
+		switch fd.Kind() {
+		case protoreflect.StringKind:
+			texts = append(texts, v.String())
+		case protoreflect.MessageKind, protoreflect.GroupKind:
+			texts = messageStrings(v.Message(), texts)
+		}
+	}
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				addValue(fd, list.Get(i))
+			}
+		case fd.IsMap():
+			v.Map().Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+				texts = append(texts, key.String())
+				addValue(fd.MapValue(), value)
+				return true
+			})
+		default:
+			addValue(fd, v)
+		}
+		return true
+	})
+	return texts
+}
+
+func parseCodes(names []string) (map[codes.Code]bool, error) {
+	parsed := make(map[codes.Code]bool, len(names))
+	for _, name := range names {
+		var code codes.Code
+		// Names are accepted the same way as in the status JSON, e.g. "INTERNAL" or "DATA_LOSS"
+		if err := code.UnmarshalJSON([]byte(`"` + strings.ToUpper(name) + `"`)); err != nil {
+			return nil, errors.Errorf("Unknown status code %s", name)
+		}
+		parsed[code] = true
+	}
+	return parsed, nil
+}
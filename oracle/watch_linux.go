@@ -0,0 +1,50 @@
+package oracle
+
+import (
+	"bytes"
+	"syscall"
+	"unsafe"
+)
+
+// watchReads calls onRead with the names of the files in the directory which are read. Only the reads are
+// watched, so creating the canary files does not count as their use.
+func watchReads(dir string, onRead func(name string)) (func(), error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	wd, err := syscall.InotifyAddWatch(fd, dir, syscall.IN_ACCESS|syscall.IN_CLOSE_NOWRITE)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	go func() {
+		defer syscall.Close(fd)
+		buf := make([]byte, 64*1024)
+		for {
+			n, err := syscall.Read(fd, buf)
+			if err != nil && err != syscall.EINTR {
+				return
+			}
+			for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				if event.Mask&syscall.IN_IGNORED != 0 {
+					// Watch was removed, either by the stop or with the directory
+					return
+				}
+				nameStart := offset + syscall.SizeofInotifyEvent
+				name := string(bytes.TrimRight(buf[nameStart:nameStart+int(event.Len)], "\x00"))
+				// Events without the name are the reads of the directory itself
+				if len(name) > 0 {
+					onRead(name)
+				}
+				offset = nameStart + int(event.Len)
+			}
+		}
+	}()
+
+	return func() {
+		syscall.InotifyRmWatch(fd, uint32(wd))
+	}, nil
+}
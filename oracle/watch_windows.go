@@ -0,0 +1,8 @@
+package oracle
+
+import "github.com/pkg/errors"
+
+// watchReads is not supported, so the path traversal canaries are not injected
+func watchReads(dir string, onRead func(name string)) (func(), error) {
+	return nil, errors.New("File reads cannot be watched on Windows!")
+}
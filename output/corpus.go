@@ -0,0 +1,69 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Corpus stores the recorded calls as seeds which are used by the fuzzing loop
+type Corpus struct {
+	Dir string
+	mu  sync.Mutex
+	cnt int
+}
+
+func NewCorpus(dir string) (*Corpus, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &Corpus{
+		Dir: dir,
+	}, nil
+}
+
+func (c *Corpus) SaveSeed(data *SeedOutput) error {
+	c.mu.Lock()
+	c.cnt++
+	seedNo := c.cnt
+	c.mu.Unlock()
+
+	mData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	pathNoSuffix := strings.Replace(strings.TrimPrefix(data.MethodPath, "/"), "/", "_", 1)
+	fFileName := fmt.Sprintf("%s_%06d_%s.json", time.Now().Format("20060102150405"), seedNo, pathNoSuffix)
+	return save(mData, filepath.Join(c.Dir, fFileName))
+}
+
+// LoadSeeds reads all seeds from the corpus directory in the order they were recorded
+func LoadSeeds(dir string) ([]SeedOutput, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	seeds := make([]SeedOutput, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var seed SeedOutput
+		if err := json.Unmarshal(data, &seed); err != nil {
+			return nil, errors.Errorf("Failed to parse the seed %s: %s", file, err)
+		}
+		seeds = append(seeds, seed)
+	}
+	return seeds, nil
+}
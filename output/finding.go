@@ -0,0 +1,62 @@
+package output
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// Kinds of the findings, each of them is stored in the output directory with the same name
+const (
+	FindingResourceLeak = "leaks"
+	FindingSlowInput    = "slow"
+	FindingStatus       = "status"
+	FindingInvariant    = "invariants"
+	FindingDivergence   = "divergences"
+	FindingRegression   = "regressions"
+	FindingInjection    = "injections"
+)
+
+// SaveFinding stores the finding in the directory of its kind. Findings are deduplicated the same way as the crashes.
+func (f *Filesystem) SaveFinding(data *Finding) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	findingDir := filepath.Join(f.OutputBaseDir, data.Kind)
+	if f.findingBuckets == nil {
+		f.findingBuckets = map[string]map[string]*crashBucket{}
+	}
+	buckets, ok := f.findingBuckets[data.Kind]
+	if !ok {
+		if err := os.MkdirAll(findingDir, 0755); err != nil {
+			return false, err
+		}
+		buckets = loadBuckets(findingDir, readFindingEntry)
+		f.findingBuckets[data.Kind] = buckets
+	}
+	return saveBucketed(findingDir, buckets, data, readFindingEntry)
+}
+
+func (d *Finding) bucketKey() (string, string, string) {
+	return d.Signature, d.MethodPath, d.Message
+}
+
+func (d *Finding) bucketHitCount() int {
+	return d.HitCount
+}
+
+func (d *Finding) setHitCount(hitCount int) {
+	d.HitCount = hitCount
+}
+
+func readFindingEntry(path string) (bucketEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	finding := &Finding{}
+	if err := json.Unmarshal(data, finding); err != nil {
+		return nil, err
+	}
+	return finding, nil
+}
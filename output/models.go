@@ -1,18 +1,74 @@
 package output
 
 type CrashOutput struct {
-	ErrorCode        string   `json:"errorCode"`
-	ErrorCause       string   `json:"errorCause"`
-	ModuleName       string   `json:"moduleName"`
-	FaultFunction    string   `json:"faultFunction"`
-	MethodPath       string   `json:"methodPath"`
-	ExecutableOutput string   `json:"executableOutput"`
-	ExecutableEvents []string `json:"executableEvents"`
-	MemoryDumpPath   string   `json:"memoryDumpPath"`
-	CrashMessage     string   `json:"crashMessage"`
+	ErrorCode        string        `json:"errorCode"`
+	ErrorCause       string        `json:"errorCause"`
+	ModuleName       string        `json:"moduleName"`
+	FaultFunction    string        `json:"faultFunction"`
+	MethodPath       string        `json:"methodPath"`
+	ExecutableOutput string        `json:"executableOutput"`
+	ExecutableEvents []string      `json:"executableEvents"`
+	MemoryDumpPath   string        `json:"memoryDumpPath"`
+	CrashMessage     string        `json:"crashMessage"`
+	ErrorDetails     string        `json:"errorDetails,omitempty"`
+	Signal           int           `json:"signal,omitempty"`
+	ExitCode         int           `json:"exitCode,omitempty"`
+	CoreDumped       bool          `json:"coreDumped,omitempty"`
+	ReportTool       string        `json:"reportTool,omitempty"`
+	BugType          string        `json:"bugType,omitempty"`
+	AccessType       string        `json:"accessType,omitempty"`
+	AccessSize       int           `json:"accessSize,omitempty"`
+	FaultAddress     string        `json:"faultAddress,omitempty"`
+	FaultingFrame    *StackFrame   `json:"faultingFrame,omitempty"`
+	StackTrace       []StackFrame  `json:"stackTrace,omitempty"`
+	Signature        string        `json:"signature,omitempty"`
+	HitCount         int           `json:"hitCount,omitempty"`
+	Context          *CrashContext `json:"context,omitempty"`
+}
+
+// CrashContext is the state of the faulting thread captured before the fatal signal was delivered
+type CrashContext struct {
+	ThreadID         int               `json:"threadId"`
+	Signal           int               `json:"signal"`
+	SignalCode       int               `json:"signalCode"`
+	FaultAddress     string            `json:"faultAddress"`
+	Registers        map[string]string `json:"registers"`
+	InstructionBytes string            `json:"instructionBytes"`
+	MemoryMap        []string          `json:"memoryMap"`
+	Backtrace        []StackFrame      `json:"backtrace"`
+}
+
+// StackFrame is a single symbolized frame of the crash stack
+type StackFrame struct {
+	Index    int    `json:"index"`
+	Address  string `json:"address,omitempty"`
+	Function string `json:"function,omitempty"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Module   string `json:"module,omitempty"`
+	Offset   string `json:"offset,omitempty"`
 }
 
 type IterationProgress struct {
 	CurrentIteration int    `json:"currentIteration"`
 	CurrentMessage   string `json:"currentMessage"`
 }
+
+// SeedOutput is a single recorded call with hex encoded messages
+type SeedOutput struct {
+	MethodPath string   `json:"methodPath"`
+	Requests   []string `json:"requests"`
+	Responses  []string `json:"responses"`
+	StatusCode string   `json:"statusCode"`
+}
+
+// Finding is a bug which has not crashed the target, e.g. a leak or a violated invariant
+type Finding struct {
+	Kind        string                 `json:"kind"`
+	MethodPath  string                 `json:"methodPath"`
+	Description string                 `json:"description"`
+	Message     string                 `json:"message"`
+	Details     map[string]interface{} `json:"details,omitempty"`
+	Signature   string                 `json:"signature,omitempty"`
+	HitCount    int                    `json:"hitCount,omitempty"`
+}
@@ -16,17 +16,31 @@ import (
 
 const (
 	CrashDirName     = "Crashes"
+	HangDirName      = "Hangs"
 	ProgressFileName = "progress.json"
 )
 
 type OutputManager interface {
-	SaveCrash(*CrashOutput) error
+	SaveCrash(*CrashOutput) (bool, error)
+	SaveHang(*CrashOutput) (bool, error)
+	SaveFinding(*Finding) (bool, error)
 	SaveProgress(*IterationProgress) error
 }
 
 type Filesystem struct {
 	OutputBaseDir string
 	mu            sync.Mutex
+	crashBuckets  map[string]*crashBucket
+	hangBuckets   map[string]*crashBucket
+	// Buckets of the findings by their kind
+	findingBuckets map[string]map[string]*crashBucket
+}
+
+// crashBucket is the stored crash with the same signature
+type crashBucket struct {
+	path     string
+	hitCount int
+	msgSize  int
 }
 
 func NewFilesystem(baseDir string) *Filesystem {
@@ -36,15 +50,85 @@ func NewFilesystem(baseDir string) *Filesystem {
 	}
 }
 
-func (f *Filesystem) SaveCrash(data *CrashOutput) error {
-	mData, err := json.Marshal(data)
-	if err != nil {
-		return err
+// SaveCrash stores the crash if its signature was not seen before and returns true in that case.
+// Duplicates only increase the hit count of the stored crash, which keeps the smallest reproducer.
+func (f *Filesystem) SaveCrash(data *CrashOutput) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	crashDir := filepath.Join(f.OutputBaseDir, CrashDirName)
+	if f.crashBuckets == nil {
+		f.crashBuckets = loadBuckets(crashDir, readCrashEntry)
+	}
+	return saveBucketed(crashDir, f.crashBuckets, data, readCrashEntry)
+}
+
+// SaveHang stores the hang the same way as the crashes, the signature of the hang is its method and error code
+func (f *Filesystem) SaveHang(data *CrashOutput) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	hangDir := filepath.Join(f.OutputBaseDir, HangDirName)
+	if f.hangBuckets == nil {
+		f.hangBuckets = loadBuckets(hangDir, readCrashEntry)
 	}
+	return saveBucketed(hangDir, f.hangBuckets, data, readCrashEntry)
+}
+
+// bucketEntry is the stored output which is deduplicated by its signature
+type bucketEntry interface {
+	bucketKey() (signature, methodPath, message string)
+	bucketHitCount() int
+	setHitCount(hitCount int)
+}
 
-	pathNoSuffix := strings.Replace(data.MethodPath, "/", "_", 1)
-	fFileName := fmt.Sprintf("%s_%s.json", time.Now().Format("20060102150405"), pathNoSuffix)
-	return save(mData, filepath.Join(f.OutputBaseDir, CrashDirName, fFileName))
+func (c *CrashOutput) bucketKey() (string, string, string) {
+	return c.Signature, c.MethodPath, c.CrashMessage
+}
+
+func (c *CrashOutput) bucketHitCount() int {
+	return c.HitCount
+}
+
+func (c *CrashOutput) setHitCount(hitCount int) {
+	c.HitCount = hitCount
+}
+
+func saveBucketed(dir string, buckets map[string]*crashBucket, data bucketEntry, read func(string) (bucketEntry, error)) (bool, error) {
+	signature, methodPath, message := data.bucketKey()
+	pathNoSuffix := strings.Replace(methodPath, "/", "_", 1)
+	if len(signature) == 0 {
+		data.setHitCount(1)
+		fFileName := fmt.Sprintf("%s_%s.json", time.Now().Format("20060102150405"), pathNoSuffix)
+		return true, saveJSON(data, filepath.Join(dir, fFileName))
+	}
+
+	bucket, ok := buckets[signature]
+	if !ok {
+		data.setHitCount(1)
+		fFileName := fmt.Sprintf("%s_%s.json", signature, pathNoSuffix)
+		bucket = &crashBucket{
+			path:     filepath.Join(dir, fFileName),
+			hitCount: 1,
+			msgSize:  len(message),
+		}
+		buckets[signature] = bucket
+		return true, saveJSON(data, bucket.path)
+	}
+
+	bucket.hitCount++
+	if len(message) < bucket.msgSize {
+		bucket.msgSize = len(message)
+		data.setHitCount(bucket.hitCount)
+		return false, saveJSON(data, bucket.path)
+	}
+
+	stored, err := read(bucket.path)
+	if err != nil {
+		return false, err
+	}
+	stored.setHitCount(bucket.hitCount)
+	return false, saveJSON(stored, bucket.path)
 }
 
 func (f *Filesystem) SaveProgress(data *IterationProgress) error {
@@ -59,22 +143,67 @@ func (f *Filesystem) SaveProgress(data *IterationProgress) error {
 	return save(mData, filepath.Join(f.OutputBaseDir, ProgressFileName))
 }
 
-func save(data []byte, path string) error {
-	return os.WriteFile(path, data, 0600)
+func saveJSON(data interface{}, path string) error {
+	mData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return save(mData, path)
 }
 
-func createBaseDirectoriesIfNotExists(baseDir string) {
-	if util.DirectoryExists(baseDir) && util.DirectoryExists(filepath.Join(baseDir, CrashDirName)) {
-		return
+func readCrash(path string) (*CrashOutput, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
 	}
+	crash := &CrashOutput{}
+	if err := json.Unmarshal(data, crash); err != nil {
+		return nil, err
+	}
+	return crash, nil
+}
+
+func readCrashEntry(path string) (bucketEntry, error) {
+	return readCrash(path)
+}
 
-	if err := os.Mkdir(baseDir, fs.FileMode(os.O_RDWR)); err != nil {
-		log.Printf("Error while creating base output directory: %s", err)
-		return
+// loadBuckets reads the outputs stored by the previous runs, so they are not reported as new again
+func loadBuckets(dir string, read func(string) (bucketEntry, error)) map[string]*crashBucket {
+	buckets := map[string]*crashBucket{}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return buckets
+	}
+	for _, file := range files {
+		entry, err := read(file)
+		if err != nil {
+			continue
+		}
+		signature, _, message := entry.bucketKey()
+		if len(signature) == 0 {
+			continue
+		}
+		buckets[signature] = &crashBucket{
+			path:     file,
+			hitCount: entry.bucketHitCount(),
+			msgSize:  len(message),
+		}
 	}
+	return buckets
+}
+
+func save(data []byte, path string) error {
+	return os.WriteFile(path, data, 0600)
+}
 
-	if err := os.Mkdir(filepath.Join(baseDir, CrashDirName), fs.FileMode(os.O_RDWR)); err != nil {
-		log.Printf("Error while creating crash output directory: %s", err)
-		return
+func createBaseDirectoriesIfNotExists(baseDir string) {
+	for _, dir := range []string{baseDir, filepath.Join(baseDir, CrashDirName), filepath.Join(baseDir, HangDirName)} {
+		if util.DirectoryExists(dir) {
+			continue
+		}
+		if err := os.Mkdir(dir, fs.FileMode(os.O_RDWR)); err != nil {
+			log.Printf("Error while creating output directory %s: %s", dir, err)
+			return
+		}
 	}
 }
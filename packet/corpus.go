@@ -0,0 +1,47 @@
+package packet
+
+import (
+	"encoding/hex"
+	"log"
+
+	"github.com/lukjok/gipcfuzz/output"
+	"github.com/lukjok/gipcfuzz/util"
+)
+
+// Stream IDs of the corpus seeds start from here, so they do not mix with the captured streams
+const corpusStreamIDBase uint32 = 1 << 30
+
+// GetCorpusMessages loads the messages of the seeds recorded by the proxy. Each seed
+// gets its own stream ID, so the requests and responses of the call stay together.
+func GetCorpusMessages(corpusPath string, protoPath string, protoIncludePath []string) []ProtoByteMsg {
+	LoadProtoDescriptions(
+		util.GetFileNamesInDirectory(protoPath, []string{"Includes"}),
+		append(protoIncludePath, protoPath))
+
+	seeds, err := output.LoadSeeds(corpusPath)
+	if err != nil {
+		log.Fatalln("Failed to load the corpus", err)
+	}
+
+	msgs := make([]ProtoByteMsg, 0, len(seeds))
+	for idx, seed := range seeds {
+		id := corpusStreamIDBase + uint32(idx)
+		msgs = append(msgs, getSeedMessages(seed.MethodPath, seed.Requests, Request, id)...)
+		msgs = append(msgs, getSeedMessages(seed.MethodPath, seed.Responses, Response, id)...)
+	}
+	return msgs
+}
+
+func getSeedMessages(path string, encMsgs []string, side MessageType, id uint32) []ProtoByteMsg {
+	msgs := make([]ProtoByteMsg, 0, len(encMsgs))
+	for _, encMsg := range encMsgs {
+		payload, err := hex.DecodeString(encMsg)
+		if err != nil {
+			continue
+		}
+		if msg, err := newByteMsg(path, payload, int(side), id); err == nil {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
@@ -3,6 +3,7 @@ package packet
 import (
 	"github.com/golang/protobuf/proto"
 	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/grpc/codes"
 )
 
 type MessageType int
@@ -25,7 +26,22 @@ type ProtoByteMsg struct {
 	Descriptor *desc.MessageDescriptor
 	Energy     int
 	StreamID   uint32
-	Message    *string
+	// Conn is the captured connection, since the stream IDs are only unique in it
+	Conn    string
+	Message *string
+}
+
+// MsgPair is the captured request with its response
+type MsgPair struct {
+	Request  ProtoByteMsg
+	Response *ProtoByteMsg
+	// Status is the captured error status of the call, OK if it succeeded or was not captured
+	Status codes.Code
+}
+
+type streamKey struct {
+	conn string
+	id   uint32
 }
 
 type MsgValDep struct {
@@ -11,6 +11,7 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -23,10 +24,12 @@ import (
 	"github.com/google/gopacket/tcpassembly/tcpreader"
 	"github.com/jhump/protoreflect/desc"
 	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/lukjok/gipcfuzz/envelope"
 	"github.com/lukjok/gipcfuzz/util"
 	"github.com/pkg/errors"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/hpack"
+	"google.golang.org/grpc/codes"
 )
 
 // httpStreamFactory implements tcpassembly.StreamFactory
@@ -44,6 +47,9 @@ var streamPath = map[string]map[uint32]string{}
 var protoDescriptors []*desc.FileDescriptor
 var pathLock sync.RWMutex
 
+// Error statuses of the captured calls
+var streamStatus = map[streamKey]codes.Code{}
+
 func (h *httpStreamFactory) New(net, transport gopacket.Flow) tcpassembly.Stream {
 	hstream := &httpStream{
 		net:       net,
@@ -82,6 +88,10 @@ func LoadProtoDescriptions(files []string, includePaths []string) {
 }
 
 func ProcessPacketSource(path string) {
+	processPacketSource(path, &httpStreamFactory{})
+}
+
+func processPacketSource(path string, streamFactory tcpassembly.StreamFactory) {
 	handle, err := pcap.OpenOffline(path)
 	if err != nil {
 		log.Fatal(err)
@@ -91,11 +101,20 @@ func ProcessPacketSource(path string) {
 	packets := gopacket.NewPacketSource(
 		handle, handle.LinkType()).Packets()
 
-	streamFactory := &httpStreamFactory{}
 	streamPool := tcpassembly.NewStreamPool(streamFactory)
 	assembler := tcpassembly.NewAssembler(streamPool)
+	socketStreams := map[uint32]tcpassembly.Stream{}
+	socketConn := 0
 	ticker := time.Tick(time.Minute)
 
+	completeSocketStreams := func() {
+		for direction, stream := range socketStreams {
+			stream.ReassemblyComplete()
+			delete(socketStreams, direction)
+		}
+	}
+	defer completeSocketStreams()
+
 	for {
 		select {
 		case packet := <-packets:
@@ -105,7 +124,30 @@ func ProcessPacketSource(path string) {
 			}
 
 			if packet.NetworkLayer() == nil || packet.TransportLayer() == nil || packet.TransportLayer().LayerType() != layers.LayerTypeTCP {
-				//log.Println("Unusable packet")
+				// Unix socket taps do not have IP and TCP layers, so the payload is the stream data itself
+				if direction, payload, ok := getSocketPayload(handle.LinkType(), packet); ok {
+					if len(socketStreams) > 0 && bytes.HasPrefix(payload, []byte(http2.ClientPreface)) {
+						// Socket captures have no addresses, so the next connection is only told by its preface
+						completeSocketStreams()
+						socketConn++
+					}
+					stream, ok := socketStreams[direction]
+					if !ok {
+						// Client sends the first data, so the first direction seen carries the requests
+						side := Response
+						if direction == socketUndirected {
+							side = Unknown
+						} else if len(socketStreams) == 0 {
+							side = Request
+						}
+						stream = streamFactory.New(socketFlows(side, socketConn))
+						socketStreams[direction] = stream
+					}
+					stream.Reassembled([]tcpassembly.Reassembly{{
+						Bytes: payload,
+						Seen:  packet.Metadata().Timestamp,
+					}})
+				}
 				continue
 			}
 			tcp := packet.TransportLayer().(*layers.TCP)
@@ -125,8 +167,11 @@ func (h *httpStream) run() {
 	framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
 	net := fmt.Sprintf("%s:%s -> %s:%s", h.net.Src(), h.transport.Src(), h.net.Dst(), h.transport.Dst())
 	revNet := fmt.Sprintf("%s:%s -> %s:%s", h.net.Dst(), h.transport.Dst(), h.net.Src(), h.transport.Src())
+	conn := connectionKey(net, revNet)
 	// 1 request, 2 response, 0 unkonwn
 	var streamSide = map[uint32]int{}
+	// gRPC-Web and Connect requests carried over HTTP/2 have their own content types
+	var streamContentType = map[uint32]string{}
 
 	defer func() {
 		pathLock.Lock()
@@ -145,6 +190,11 @@ func (h *httpStream) run() {
 
 		prefix := string(peekBuf)
 
+		if isHTTP1Prefix(prefix) {
+			h.runHTTP1(buf, conn)
+			return
+		}
+
 		if strings.HasPrefix(prefix, "PRI") {
 			buf.Discard(len(http2.ClientPreface))
 		}
@@ -173,6 +223,10 @@ func (h *httpStream) run() {
 					streamSide[id] = 1
 				} else if hf.Name == ":status" {
 					streamSide[id] = 2
+				} else if hf.Name == "content-type" {
+					streamContentType[id] = hf.Value
+				} else if hf.Name == "grpc-status" {
+					recordStatus(conn, id, hf.Value)
 				}
 			}
 		case *http2.DataFrame:
@@ -188,19 +242,47 @@ func (h *httpStream) run() {
 			}
 
 			pathLock.RUnlock()
+			if envelope.IsWebContentType(streamContentType[id]) {
+				appendWebMessages(conn, path, streamContentType[id], frame.Data(), streamSide[id], id)
+				continue
+			}
 			if msg, err := ParseFrameToByteMsg(net, path, frame, streamSide[id]); err == nil {
-				pathMsgs = append(pathMsgs, msg)
-				pathMsgsCount++
+				msg.Conn = conn
+				appendMessage(msg)
 			}
 		default:
 		}
 	}
 }
 
+// connectionKey is the same for both directions of the connection
+func connectionKey(net, revNet string) string {
+	if revNet < net {
+		return revNet
+	}
+	return net
+}
+
+// recordStatus keeps the error status of the captured call, so the replay can tell a fixed error from a regression
+func recordStatus(conn string, id uint32, value string) {
+	code, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || codes.Code(code) == codes.OK {
+		return
+	}
+	msgsLock.Lock()
+	streamStatus[streamKey{conn, id}] = codes.Code(code)
+	msgsLock.Unlock()
+}
+
+func capturedStatus(key streamKey) codes.Code {
+	msgsLock.Lock()
+	defer msgsLock.Unlock()
+	return streamStatus[key]
+}
+
 func ParseFrameToByteMsg(net string, path string, frame *http2.DataFrame, side int) (ProtoByteMsg, error) {
 	buf := frame.Data()
 	id := frame.Header().StreamID
-	compress := buf[0]
 
 	if len(buf) == 0 {
 		return ProtoByteMsg{
@@ -221,7 +303,7 @@ func ParseFrameToByteMsg(net string, path string, frame *http2.DataFrame, side i
 	// 	}, &proto.ParseError{}
 	// }
 
-	if compress == 1 {
+	if compress := buf[0]; compress == 1 {
 		// use compression, check Message-Encoding later
 		log.Printf("%s %d use compression, msg %q", net, id, buf[5:])
 		return ProtoByteMsg{
@@ -233,13 +315,18 @@ func ParseFrameToByteMsg(net string, path string, frame *http2.DataFrame, side i
 		}, errors.New("Message is using compression!")
 	}
 
-	if len(protoDescriptors) > 0 {
+	return newByteMsg(path, buf[5:], side, id)
+}
+
+// newByteMsg matches the message payload against the loaded proto descriptors
+func newByteMsg(path string, payload []byte, side int, id uint32) (ProtoByteMsg, error) {
+	if len(protoDescriptors) > 0 && len(path) > 0 {
 		for _, dscr := range protoDescriptors {
 			oldPath := strings.Replace(path[1:], "/", ".", 1)
 			sym := dscr.FindSymbol(oldPath)
 			if sym != nil {
 				mDsc := sym.(*desc.MethodDescriptor)
-				encMsg := hex.EncodeToString(buf[5:])
+				encMsg := hex.EncodeToString(payload)
 				if MessageType(side) == Request {
 					return ProtoByteMsg{
 						Path:       path[1:],
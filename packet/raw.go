@@ -0,0 +1,155 @@
+package packet
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/tcpassembly"
+	"github.com/google/gopacket/tcpassembly/tcpreader"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/lukjok/gipcfuzz/util"
+)
+
+// Messages bigger than this are treated as a broken framing
+const rawMaxMessageSize = 64 << 20
+
+// rawStreamFactory implements tcpassembly.StreamFactory for varint length-delimited protobuf streams
+type rawStreamFactory struct {
+	serverPort   string
+	requestType  *desc.MessageDescriptor
+	responseType *desc.MessageDescriptor
+}
+
+// rawStream will handle the decoding of the length-delimited messages of one direction
+type rawStream struct {
+	net, transport gopacket.Flow
+	r              tcpreader.ReaderStream
+	factory        *rawStreamFactory
+}
+
+func (f *rawStreamFactory) New(net, transport gopacket.Flow) tcpassembly.Stream {
+	rstream := &rawStream{
+		net:       net,
+		transport: transport,
+		r:         tcpreader.NewReaderStream(),
+		factory:   f,
+	}
+	go rstream.run()
+	return &rstream.r
+}
+
+// GetParsedRawMessages extracts the messages from the capture of the plain length-delimited protobuf traffic.
+// Messages sent to the server port are requests, the ones sent from it are responses.
+func GetParsedRawMessages(path string, protoPath string, protoIncludePath []string, requestType string, responseType string, serverPort string) []ProtoByteMsg {
+	LoadProtoDescriptions(
+		util.GetFileNamesInDirectory(protoPath, []string{"Includes"}),
+		append(protoIncludePath, protoPath))
+
+	factory := &rawStreamFactory{
+		serverPort:   serverPort,
+		requestType:  findMessageDescriptor(requestType),
+		responseType: findMessageDescriptor(responseType),
+	}
+	if factory.requestType == nil {
+		log.Fatalf("Raw request type %s was not found in the proto descriptions", requestType)
+	}
+
+	processPacketSource(path, factory)
+	return pathMsgs
+}
+
+func findMessageDescriptor(name string) *desc.MessageDescriptor {
+	if len(name) == 0 {
+		return nil
+	}
+	for _, dscr := range protoDescriptors {
+		if msgDsc, ok := dscr.FindSymbol(name).(*desc.MessageDescriptor); ok {
+			return msgDsc
+		}
+	}
+	return nil
+}
+
+func (r *rawStream) run() {
+	buf := bufio.NewReader(&r.r)
+	defer tcpreader.DiscardBytesToEOF(buf)
+
+	side := r.getSide()
+	conn := rawConnectionKey(r.net, r.transport)
+	// Messages are numbered by their side, so the n-th request and the n-th response share the stream ID
+	msgNo := map[MessageType]uint32{}
+
+	for {
+		msgLen, err := binary.ReadUvarint(buf)
+		if err != nil {
+			return
+		}
+		if msgLen > rawMaxMessageSize {
+			log.Printf("Raw message length %d is too big, skipping the rest of the stream", msgLen)
+			return
+		}
+
+		msgBuf := make([]byte, msgLen)
+		if _, err := io.ReadFull(buf, msgBuf); err != nil {
+			return
+		}
+		msgSide := side
+		if msgSide == Unknown {
+			msgSide = r.factory.guessSide(msgBuf)
+		}
+		msgNo[msgSide]++
+
+		dsc := r.factory.requestType
+		if msgSide == Response {
+			dsc = r.factory.responseType
+		}
+		if dsc == nil {
+			continue
+		}
+
+		encMsg := hex.EncodeToString(msgBuf)
+		appendMessage(ProtoByteMsg{
+			Path:       r.factory.requestType.GetFullyQualifiedName(),
+			Type:       msgSide,
+			StreamID:   msgNo[msgSide],
+			Conn:       conn,
+			Descriptor: dsc,
+			Message:    &encMsg,
+		})
+	}
+}
+
+func (r *rawStream) getSide() MessageType {
+	if side := socketSide(r.transport); side != Unknown {
+		return side
+	}
+	if r.transport.Dst().String() == r.factory.serverPort {
+		return Request
+	}
+	if r.transport.Src().String() == r.factory.serverPort {
+		return Response
+	}
+	return Unknown
+}
+
+// guessSide is used only when the capture has no direction at all (RAW link type Unix socket captures),
+// then the message is treated as a request if it can be parsed as one
+func (f *rawStreamFactory) guessSide(msg []byte) MessageType {
+	if f.responseType == nil || dynamic.NewMessage(f.requestType).Unmarshal(msg) == nil {
+		return Request
+	}
+	return Response
+}
+
+// rawConnectionKey returns the connection of the stream, which is the same for both of its directions
+func rawConnectionKey(net, transport gopacket.Flow) string {
+	return connectionKey(
+		fmt.Sprintf("%s:%s -> %s:%s", net.Src(), transport.Src(), net.Dst(), transport.Dst()),
+		fmt.Sprintf("%s:%s -> %s:%s", net.Dst(), transport.Dst(), net.Src(), transport.Src()))
+}
@@ -36,20 +36,38 @@ func CalculateReqResRelations(msgs []ProtoByteMsg) []MsgValDep {
 //Orders message list in this order [rqMsg1; rsMsg1; rqMsgn; rsMsgn]
 func sortReqResOrder(msgs []ProtoByteMsg) []ProtoByteMsg {
 	sortedMsgs := make([]ProtoByteMsg, 0, 1)
-	keys := make(map[int]bool)
+	for _, pair := range PairResponses(msgs) {
+		if pair.Response != nil {
+			sortedMsgs = append(sortedMsgs, pair.Request, *pair.Response)
+		}
+	}
+	return sortedMsgs
+}
+
+// PairResponses returns all requests in the capture order. The first request of each stream of the connection
+// gets the response and the error status of the stream if they were captured, the error responses have no message.
+func PairResponses(msgs []ProtoByteMsg) []MsgPair {
+	pairs := make([]MsgPair, 0, len(msgs)/2)
+	keys := make(map[streamKey]bool)
 	for i := 0; i < len(msgs); i++ {
-		if _, value := keys[int(msgs[i].StreamID)]; !value && msgs[i].Type == Request {
+		if msgs[i].Type != Request {
+			continue
+		}
+		pair := MsgPair{Request: msgs[i]}
+		key := streamKey{msgs[i].Conn, msgs[i].StreamID}
+		if !keys[key] {
+			keys[key] = true
+			pair.Status = capturedStatus(key)
 			for j := 0; j < len(msgs); j++ {
-				if msgs[i].StreamID == msgs[j].StreamID && msgs[j].Type == Response {
-					sortedMsgs = append(sortedMsgs, msgs[i])
-					sortedMsgs = append(sortedMsgs, msgs[j])
-					keys[int(msgs[i].StreamID)] = true
+				if msgs[j].Type == Response && key == (streamKey{msgs[j].Conn, msgs[j].StreamID}) {
+					pair.Response = &msgs[j]
 					break
 				}
 			}
 		}
+		pairs = append(pairs, pair)
 	}
-	return sortedMsgs
+	return pairs
 }
 
 func DissectMsgsCommonFields(msg1, msg2 ProtoByteMsg) (MsgValDep, error) {
@@ -0,0 +1,84 @@
+package packet
+
+import (
+	"encoding/binary"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Pseudo ports of the Unix socket streams, the client side is the one which sends the first data
+const (
+	socketClientPort = 1
+	socketServerPort = 2
+)
+
+// socketUndirected is the direction of the RAW link type captures, which have both directions in one stream
+const socketUndirected = ^uint32(0)
+
+// getSocketPayload extracts stream data from captures of Unix socket traffic (e.g. socat based taps).
+// Such captures use NULL/LOOP link types where the family field is used to tell the directions apart,
+// or the RAW link type where every packet is just the next chunk of the stream.
+func getSocketPayload(linkType layers.LinkType, packet gopacket.Packet) (uint32, []byte, bool) {
+	// Packets with the decoded network layer are regular IP traffic (e.g. UDP) and not socket data
+	if packet.NetworkLayer() != nil {
+		return 0, nil, false
+	}
+
+	data := packet.Data()
+	switch linkType {
+	case layers.LinkTypeNull, layers.LinkTypeLoop:
+		if len(data) <= 4 {
+			return 0, nil, false
+		}
+		// Same byte order detection as in the gopacket loopback decoder
+		var family uint32
+		if data[0] == 0 && data[1] == 0 {
+			family = binary.BigEndian.Uint32(data[:4])
+		} else {
+			family = binary.LittleEndian.Uint32(data[:4])
+		}
+		return family, data[4:], true
+	case layers.LinkTypeRaw:
+		if len(data) == 0 {
+			return 0, nil, false
+		}
+		return socketUndirected, data, true
+	default:
+		return 0, nil, false
+	}
+}
+
+// socketFlows returns the flows used for Unix socket streams. The client and the server directions get
+// the mirrored flows, like the two directions of a TCP connection, so the side of the stream is known.
+// Each connection of the capture gets its own loopback address, so the streams of the connections stay apart.
+func socketFlows(side MessageType, conn int) (gopacket.Flow, gopacket.Flow) {
+	loopback := []byte{127, byte(conn >> 16), byte(conn >> 8), byte(conn) + 1}
+	src, dst := socketPort(0), socketPort(0)
+	switch side {
+	case Request:
+		src, dst = socketPort(socketClientPort), socketPort(socketServerPort)
+	case Response:
+		src, dst = socketPort(socketServerPort), socketPort(socketClientPort)
+	}
+	return gopacket.NewFlow(layers.EndpointIPv4, loopback, loopback), gopacket.NewFlow(layers.EndpointTCPPort, src, dst)
+}
+
+// socketSide returns the side of the Unix socket stream, or Unknown if the direction was not captured
+func socketSide(transport gopacket.Flow) MessageType {
+	_, request := socketFlows(Request, 0)
+	_, response := socketFlows(Response, 0)
+	switch transport {
+	case request:
+		return Request
+	case response:
+		return Response
+	}
+	return Unknown
+}
+
+func socketPort(port uint16) []byte {
+	buf := make([]byte, 2)
+	binary.BigEndian.PutUint16(buf, port)
+	return buf
+}
@@ -0,0 +1,178 @@
+package packet
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/lukjok/gipcfuzz/envelope"
+)
+
+type pendingHTTP1Request struct {
+	path     string
+	streamID uint32
+}
+
+// http1Queue passes the requests of the connection to its response side in the order they were sent
+type http1Queue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []pendingHTTP1Request
+	// Request side has ended, so no more requests are coming
+	done bool
+}
+
+// HTTP/1.1 has no stream IDs, so generated ones start above the HTTP/2 (31-bit) stream ID range
+var http1StreamID uint32 = 1 << 31
+var http1Queues = map[string]*http1Queue{}
+var msgsLock sync.Mutex
+
+// getHTTP1Queue returns the queue of the connection, whichever direction comes first creates it
+func getHTTP1Queue(conn string) *http1Queue {
+	pathLock.Lock()
+	defer pathLock.Unlock()
+	queue, ok := http1Queues[conn]
+	if !ok {
+		queue = &http1Queue{}
+		queue.cond = sync.NewCond(&queue.mu)
+		http1Queues[conn] = queue
+	}
+	return queue
+}
+
+func (q *http1Queue) push(pending pendingHTTP1Request) {
+	q.mu.Lock()
+	q.pending = append(q.pending, pending)
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+func (q *http1Queue) close() {
+	q.mu.Lock()
+	q.done = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// pop waits for the request answered by the next response. It returns false if the request side
+// has ended without it.
+func (q *http1Queue) pop() (pendingHTTP1Request, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.pending) == 0 && !q.done {
+		q.cond.Wait()
+	}
+	if len(q.pending) == 0 {
+		return pendingHTTP1Request{}, false
+	}
+	pending := q.pending[0]
+	q.pending = q.pending[1:]
+	return pending, true
+}
+
+func isHTTP1Prefix(prefix string) bool {
+	return strings.HasPrefix(prefix, "POST ") || strings.HasPrefix(prefix, "HTTP/1.")
+}
+
+// runHTTP1 parses gRPC-Web and Connect messages sent over HTTP/1.1. Each direction is read by its own
+// goroutine, so the response side waits for the request it answers, and they are matched in the order
+// they appear in the connection.
+func (h *httpStream) runHTTP1(buf *bufio.Reader, conn string) {
+	queue := getHTTP1Queue(conn)
+	var sentRequests, readResponses bool
+	defer func() {
+		// Requests which are not answered yet stay queued until the response side ends
+		if sentRequests {
+			queue.close()
+		}
+		if readResponses {
+			pathLock.Lock()
+			delete(http1Queues, conn)
+			pathLock.Unlock()
+		}
+	}()
+
+	for {
+		peekBuf, err := buf.Peek(5)
+		if err != nil {
+			return
+		}
+
+		if strings.HasPrefix(string(peekBuf), "HTTP/") {
+			res, err := http.ReadResponse(buf, nil)
+			if err != nil {
+				return
+			}
+			body, err := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil && err != io.ErrUnexpectedEOF {
+				return
+			}
+
+			readResponses = true
+			pending, ok := queue.pop()
+			if !ok {
+				continue
+			}
+
+			contentType := res.Header.Get("Content-Type")
+			if envelope.IsWebContentType(contentType) && res.StatusCode == http.StatusOK {
+				appendWebMessages(conn, pending.path, contentType, body, int(Response), pending.streamID)
+			}
+			// Error status is in the headers of the trailers-only response or in the trailers at the end of the body
+			if value := res.Header.Get("Grpc-Status"); len(value) > 0 {
+				recordStatus(conn, pending.streamID, value)
+			} else if value, ok := envelope.TrailerStatus(contentType, body); ok {
+				recordStatus(conn, pending.streamID, value)
+			}
+			continue
+		}
+
+		req, err := http.ReadRequest(buf)
+		if err != nil {
+			return
+		}
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return
+		}
+
+		pending := pendingHTTP1Request{
+			path:     req.URL.Path,
+			streamID: atomic.AddUint32(&http1StreamID, 1),
+		}
+		sentRequests = true
+		queue.push(pending)
+
+		contentType := req.Header.Get("Content-Type")
+		if envelope.IsWebContentType(contentType) {
+			appendWebMessages(conn, pending.path, contentType, body, int(Request), pending.streamID)
+		}
+	}
+}
+
+// appendWebMessages decodes all messages of the gRPC-Web or Connect body and adds them to the parsed message list
+func appendWebMessages(conn string, path string, contentType string, body []byte, side int, id uint32) {
+	payloads, err := envelope.DecodeMessages(contentType, body)
+	if err != nil {
+		return
+	}
+	for _, payload := range payloads {
+		if msg, err := newByteMsg(path, payload, side, id); err == nil {
+			msg.Conn = conn
+			appendMessage(msg)
+		}
+	}
+}
+
+func appendMessage(msg ProtoByteMsg) {
+	msgsLock.Lock()
+	pathMsgs = append(pathMsgs, msg)
+	pathMsgsCount++
+	msgsLock.Unlock()
+}
@@ -0,0 +1,321 @@
+package proxy
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/lukjok/gipcfuzz/communication"
+	"github.com/lukjok/gipcfuzz/config"
+	"github.com/lukjok/gipcfuzz/mutator"
+	"github.com/lukjok/gipcfuzz/output"
+	"github.com/lukjok/gipcfuzz/util"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const dialTimeout = 10 * time.Second
+
+// Proxy forwards the gRPC calls to the target and records every call as a seed.
+// Messages can be mutated in flight with the configured probability.
+type Proxy struct {
+	Logger   *util.Log
+	Settings config.ProxyConfig
+	Corpus   *output.Corpus
+	server   *grpc.Server
+	listener net.Listener
+	conn     *grpc.ClientConn
+	methods  map[string]*desc.MethodDescriptor
+	mutMgr   *mutator.MutatorManager
+	rand     *rand.Rand
+	mu       sync.Mutex
+}
+
+func NewProxy(settings config.Configuration, logger *util.Log) (*Proxy, error) {
+	if len(settings.Proxy.ListenAddress) == 0 {
+		return nil, errors.New("Proxy listen address is not set!")
+	}
+
+	corpus, err := output.NewCorpus(util.GetCorpusPath(settings))
+	if err != nil {
+		return nil, errors.WithMessage(err, "Failed to create the corpus directory")
+	}
+
+	methods, err := loadMethods(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dialTarget(util.GetTargetEndpoint(settings))
+	if err != nil {
+		return nil, err
+	}
+
+	network, address, err := communication.ParseTarget(settings.Proxy.ListenAddress)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if network == "unix" && !strings.HasPrefix(address, "@") {
+		os.Remove(address)
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		conn.Close()
+		return nil, errors.WithMessagef(err, "Failed to listen on %s", settings.Proxy.ListenAddress)
+	}
+
+	rSrc := rand.NewSource(time.Now().UnixNano())
+	var mutStrategy mutator.MutationStrategy
+	if settings.DoSingleFieldMutation {
+		mutStrategy = mutator.SingleField
+	} else {
+		mutStrategy = mutator.WholeMessage
+	}
+	mutMgr := new(mutator.MutatorManager)
+	mutMgr.New(new(mutator.DefaultDependencyUnawareMut), new(mutator.DefaultDependencyAwareMut), int(settings.MaxMsgSize), rSrc, []string{}, mutStrategy)
+
+	p := &Proxy{
+		Logger:   logger,
+		Settings: settings.Proxy,
+		Corpus:   corpus,
+		listener: listener,
+		conn:     conn,
+		methods:  methods,
+		mutMgr:   mutMgr,
+		rand:     rand.New(rSrc),
+	}
+	p.server = grpc.NewServer(
+		grpc.ForceServerCodec(communication.RawCodec{}),
+		grpc.UnknownServiceHandler(p.handleStream))
+	return p, nil
+}
+
+// Serve blocks until the proxy is stopped
+func (p *Proxy) Serve() error {
+	return p.server.Serve(p.listener)
+}
+
+func (p *Proxy) Stop() {
+	p.server.Stop()
+	p.conn.Close()
+}
+
+func loadMethods(settings config.Configuration) (map[string]*desc.MethodDescriptor, error) {
+	protoFiles := util.GetFileFullPathInDirectory(settings.ProtoFilesPath, []string{"Includes"})
+	source, err := communication.DescriptorSourceFromProtoFiles(settings.ProtoFilesIncludePath, protoFiles...)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Failed to process proto source files")
+	}
+
+	services, err := source.ListServices()
+	if err != nil {
+		return nil, err
+	}
+
+	methods := map[string]*desc.MethodDescriptor{}
+	for _, svcName := range services {
+		dsc, err := source.FindSymbol(svcName)
+		if err != nil {
+			continue
+		}
+		if svc, ok := dsc.(*desc.ServiceDescriptor); ok {
+			for _, mtd := range svc.GetMethods() {
+				methods[fmt.Sprintf("/%s/%s", svc.GetFullyQualifiedName(), mtd.GetName())] = mtd
+			}
+		}
+	}
+	return methods, nil
+}
+
+func dialTarget(target string) (*grpc.ClientConn, error) {
+	network, address, err := communication.ParseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []grpc.DialOption{}
+	if network == "unix" {
+		// Socket paths are not valid authorities
+		opts = append(opts, grpc.WithAuthority("localhost"))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	conn, err := communication.BlockingDial(ctx, network, address, nil, opts...)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "Failed to connect to the target %s", target)
+	}
+	return conn, nil
+}
+
+// handleStream forwards any call in both directions. All methods are handled as bidirectional
+// streams, since the framing of the other method types is the same.
+func (p *Proxy) handleStream(_ interface{}, serverStream grpc.ServerStream) error {
+	fullMethod, ok := grpc.MethodFromServerStream(serverStream)
+	if !ok {
+		return status.Error(codes.Internal, "Failed to get the method of the call")
+	}
+	mtd := p.methods[fullMethod]
+
+	ctx, cancel := context.WithCancel(serverStream.Context())
+	defer cancel()
+	if md, ok := metadata.FromIncomingContext(serverStream.Context()); ok {
+		ctx = metadata.NewOutgoingContext(ctx, md.Copy())
+	}
+
+	clientStream, err := p.conn.NewStream(
+		ctx,
+		&grpc.StreamDesc{ServerStreams: true, ClientStreams: true},
+		fullMethod,
+		grpc.ForceCodec(communication.RawCodec{}))
+	if err != nil {
+		return err
+	}
+
+	call := &recordedCall{seed: output.SeedOutput{
+		MethodPath: fullMethod,
+		Requests:   make([]string, 0, 1),
+		Responses:  make([]string, 0, 1),
+	}}
+
+	reqErr := make(chan error, 1)
+	resErr := make(chan error, 1)
+	go func() {
+		reqErr <- p.forwardRequests(mtd, serverStream, clientStream, call)
+	}()
+	go func() {
+		resErr <- p.forwardResponses(mtd, clientStream, serverStream, call)
+	}()
+
+	for {
+		select {
+		case err := <-reqErr:
+			if err != nil {
+				// The client went away, so the call to the target is cancelled too
+				cancel()
+				return status.Errorf(codes.Unavailable, "Failed to forward the request: %s", err)
+			}
+		case err := <-resErr:
+			serverStream.SetTrailer(clientStream.Trailer())
+			if err == io.EOF {
+				err = nil
+			}
+			p.saveCall(call, status.Code(err))
+			return err
+		}
+	}
+}
+
+func (p *Proxy) forwardRequests(mtd *desc.MethodDescriptor, src grpc.ServerStream, dst grpc.ClientStream, call *recordedCall) error {
+	for {
+		var request []byte
+		if err := src.RecvMsg(&request); err != nil {
+			if err == io.EOF {
+				return dst.CloseSend()
+			}
+			return err
+		}
+		call.addRequest(request)
+
+		if mtd != nil && p.Settings.MutateRequests {
+			request = p.mutate(mtd.GetInputType(), request)
+		}
+		if err := dst.SendMsg(&request); err != nil {
+			// The actual error is returned from the receiving side
+			return nil
+		}
+	}
+}
+
+func (p *Proxy) forwardResponses(mtd *desc.MethodDescriptor, src grpc.ClientStream, dst grpc.ServerStream, call *recordedCall) error {
+	if header, err := src.Header(); err == nil {
+		if err := dst.SendHeader(header); err != nil {
+			return err
+		}
+	}
+
+	for {
+		var response []byte
+		if err := src.RecvMsg(&response); err != nil {
+			return err
+		}
+		call.addResponse(response)
+
+		if mtd != nil && p.Settings.MutateResponses {
+			response = p.mutate(mtd.GetOutputType(), response)
+		}
+		if err := dst.SendMsg(&response); err != nil {
+			return err
+		}
+	}
+}
+
+// mutate returns the mutated message with the configured probability or the original one otherwise
+func (p *Proxy) mutate(dsc *desc.MessageDescriptor, msgBuf []byte) []byte {
+	// Mutator manager is not safe to be used from multiple calls at once
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.rand.Float64() >= p.Settings.MutationProbability {
+		return msgBuf
+	}
+
+	message := dynamic.NewMessage(dsc)
+	if err := message.Unmarshal(msgBuf); err != nil {
+		p.Logger.LogError(err.Error())
+		return msgBuf
+	}
+
+	mutBuf := append([]byte{}, msgBuf...)
+	if err := p.mutMgr.DoMutation(dsc, message, &mutBuf); err != nil {
+		p.Logger.LogError(err.Error())
+		return msgBuf
+	}
+	return mutBuf
+}
+
+func (p *Proxy) saveCall(call *recordedCall, code codes.Code) {
+	call.mu.Lock()
+	defer call.mu.Unlock()
+
+	call.seed.StatusCode = code.String()
+	if len(call.seed.Requests) == 0 {
+		return
+	}
+	if err := p.Corpus.SaveSeed(&call.seed); err != nil {
+		p.Logger.LogError(err.Error())
+		return
+	}
+	p.Logger.LogInfo(fmt.Sprintf("Recorded the call of %s", call.seed.MethodPath))
+}
+
+// recordedCall holds the original messages of the call, since both directions are forwarded at once
+type recordedCall struct {
+	seed output.SeedOutput
+	mu   sync.Mutex
+}
+
+func (c *recordedCall) addRequest(msg []byte) {
+	c.mu.Lock()
+	c.seed.Requests = append(c.seed.Requests, hex.EncodeToString(msg))
+	c.mu.Unlock()
+}
+
+func (c *recordedCall) addResponse(msg []byte) {
+	c.mu.Lock()
+	c.seed.Responses = append(c.seed.Responses, hex.EncodeToString(msg))
+	c.mu.Unlock()
+}
@@ -5,6 +5,15 @@ Stalker.trustThreshold = 0;
 var stalkerEvents = [];
 var covTarget = {};
 var execTime = undefined;
+var moduleCalls = [];
+var moduleCallSet = {};
+var moduleBlocks = {};
+var moduleEdges = {};
+var followedThreads = [];
+
+function inModule(module, address) {
+	return address.compare(module.base) >= 0 && address.compare(module.base.add(module.size)) < 0;
+}
 
 rpc.exports = {
 	startCoverageFeed: function() {
@@ -85,6 +94,78 @@ rpc.exports = {
 	},
 	clearCoverage: function() {
 		stalkerEvents = [];
+	},
+	startModuleCoverage: function(target) {
+		// Whole module is traced on every thread, since the handler is not known yet
+		const module = Process.findModuleByName(target[0]);
+		if (module == null) {
+			throw "Cannot find specified module!";
+		}
+
+		const scriptThread = Process.getCurrentThreadId();
+		Process.enumerateThreads().forEach(function (thread) {
+			if (thread.id === scriptThread) {
+				return;
+			}
+			// Function entered at every call depth of the thread, null for the functions outside of the module
+			const callStack = {};
+			Stalker.follow(thread.id, {
+				events: {
+					call: true,
+					ret: false,
+					exec: false,
+					block: true,
+					compile: false
+				},
+				onReceive: function (events) {
+					const parsed = Stalker.parse(events, {
+						stringify: false,
+						annotate: true
+					});
+					parsed.forEach(function (ev) {
+						// Call targets are the function entries, block events come on every execution of the block
+						if (ev[0] === "call") {
+							const depth = ev[3];
+							const offset = inModule(module, ev[2]) ? ev[2].sub(module.base).toString() : null;
+							if (offset !== null) {
+								if (!moduleCallSet[offset]) {
+									moduleCallSet[offset] = true;
+									moduleCalls.push(offset);
+								}
+								const caller = callStack[depth - 1];
+								moduleEdges[(caller ? caller : "") + ">" + offset] = true;
+							}
+							callStack[depth] = offset;
+						} else if (ev[0] === "block" && inModule(module, ev[1])) {
+							moduleBlocks[ev[1].sub(module.base).toString()] = true;
+						}
+					});
+				}
+			});
+			followedThreads.push(thread.id);
+		});
+		return true;
+	},
+	getModuleCoverage: function() {
+		Stalker.flush();
+		const edges = Object.keys(moduleEdges).map(function (edge) {
+			return edge.split(">");
+		});
+		return {calls: moduleCalls, blocks: Object.keys(moduleBlocks), edges: edges};
+	},
+	clearModuleCoverage: function() {
+		moduleCalls = [];
+		moduleCallSet = {};
+		moduleBlocks = {};
+		moduleEdges = {};
+	},
+	stopModuleCoverage: function() {
+		followedThreads.forEach(function (threadId) {
+			Stalker.unfollow(threadId);
+		});
+		followedThreads = [];
+		Stalker.flush();
+		Stalker.garbageCollect();
 	}
 }
 
@@ -0,0 +1,72 @@
+package symbol
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/lukjok/gipcfuzz/output"
+	"github.com/pkg/errors"
+)
+
+// Cache keeps the opened modules, so the debug information is read only once per module
+type Cache struct {
+	mu      sync.Mutex
+	modules map[string]*Symbolizer
+}
+
+func NewCache() *Cache {
+	return &Cache{modules: make(map[string]*Symbolizer)}
+}
+
+// Get returns the symbolizer of the module. Modules which failed to open are remembered as nil.
+func (c *Cache) Get(path string) (*Symbolizer, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, ok := c.modules[path]; ok {
+		if s == nil {
+			return nil, errors.Errorf("Module %s has no usable symbols!", path)
+		}
+		return s, nil
+	}
+	s, err := Open(path)
+	c.modules[path] = s
+	return s, err
+}
+
+// SymbolizeFrame fills the missing function, file and line of the frame from its module and offset
+func (c *Cache) SymbolizeFrame(frame *output.StackFrame) {
+	if len(frame.Module) == 0 || len(frame.Offset) == 0 || (len(frame.Function) > 0 && len(frame.File) > 0) {
+		return
+	}
+	offset, err := strconv.ParseUint(frame.Offset, 0, 64)
+	if err != nil {
+		return
+	}
+	s, err := c.Get(frame.Module)
+	if err != nil {
+		return
+	}
+	// Return addresses point after the call, so the previous byte belongs to the calling line
+	if frame.Index > 0 && offset > 0 {
+		offset--
+	}
+	sym, ok := s.Symbolize(offset)
+	if !ok {
+		return
+	}
+	if len(frame.Function) == 0 {
+		frame.Function = sym.Function
+	}
+	if len(frame.File) == 0 {
+		frame.File = sym.File
+		frame.Line = sym.Line
+	}
+}
+
+// SymbolizeFrames symbolizes every frame of the stack in place
+func (c *Cache) SymbolizeFrames(frames []output.StackFrame) {
+	for i := range frames {
+		c.SymbolizeFrame(&frames[i])
+	}
+}
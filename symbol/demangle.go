@@ -0,0 +1,49 @@
+package symbol
+
+import (
+	"strconv"
+	"strings"
+)
+
+// demangle turns the Itanium C++ names of the functions into the qualified names without the
+// parameters (e.g. "_ZN4test11TestService12MethodOneBadEv" into "test::TestService::MethodOneBad").
+// Only the plain nested names are handled, which is enough for the service methods. Names with
+// templates or substitutions are returned as is.
+func demangle(name string) (string, bool) {
+	if !strings.HasPrefix(name, "_Z") {
+		return name, false
+	}
+	rest := name[2:]
+	nested := strings.HasPrefix(rest, "N")
+	if nested {
+		rest = strings.TrimLeft(rest[1:], "rVK")
+	}
+
+	parts := make([]string, 0, 4)
+	for len(rest) > 0 {
+		if nested && rest[0] == 'E' {
+			break
+		}
+		n := 0
+		for n < len(rest) && rest[n] >= '0' && rest[n] <= '9' {
+			n++
+		}
+		if n == 0 {
+			// Constructors, destructors, operators, templates and substitutions
+			return name, false
+		}
+		length, _ := strconv.Atoi(rest[:n])
+		if n+length > len(rest) {
+			return name, false
+		}
+		parts = append(parts, rest[n:n+length])
+		rest = rest[n+length:]
+		if !nested {
+			break
+		}
+	}
+	if len(parts) == 0 || (nested && !strings.HasPrefix(rest, "E")) {
+		return name, false
+	}
+	return strings.Join(parts, "::"), true
+}
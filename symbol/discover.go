@@ -0,0 +1,152 @@
+package symbol
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// MethodMatch is the result of looking for the handler of a single RPC method
+type MethodMatch struct {
+	Method     string
+	Match      *Function
+	Candidates []Function
+}
+
+// Ambiguous tells if several functions fit the method equally well
+func (m MethodMatch) Ambiguous() bool {
+	return m.Match == nil && len(m.Candidates) > 1
+}
+
+// Qualifiers of the generated client stubs and default implementations, which are never the handlers
+var generatedQualifiers = []string{"Stub", "Client", "Unimplemented", "WithAsyncMethod", "WithCallbackMethod",
+	"WithRawMethod", "WithStreamedUnaryMethod", "WithSplitStreamingMethod", "WithGenericMethod", "ExperimentalWith"}
+
+// MatchMethods looks for the handler function of every method (e.g. "test.testService/MethodOneBad").
+// Function matches when its last name component is the method name, e.g. "test::TestServiceImpl::MethodOneBad"
+// in C++, "main.(*testServiceServer).MethodOneBad" in Go or "server::TestService::method_one_bad" in Rust.
+// Functions qualified by the service name are preferred over the other ones.
+func (s *Symbolizer) MatchMethods(methods []string) []MethodMatch {
+	byName := make(map[string][]Function)
+	for _, fn := range s.Functions() {
+		if fn.Offset == 0 {
+			continue
+		}
+		name := lastComponent(fn.Name)
+		byName[name] = append(byName[name], fn)
+	}
+
+	matches := make([]MethodMatch, 0, len(methods))
+	for _, method := range methods {
+		service, methodName := splitMethod(method)
+		candidates := make([]Function, 0, len(byName[methodName]))
+		candidates = append(candidates, byName[methodName]...)
+		if snake := snakeCase(methodName); snake != methodName {
+			candidates = append(candidates, byName[snake]...)
+		}
+
+		best := -1 << 31
+		bestFunctions := make([]Function, 0, 2)
+		for _, fn := range candidates {
+			if isGenerated(fn.Name) {
+				continue
+			}
+			score := scoreCandidate(fn.Name, service)
+			if score > best {
+				best = score
+				bestFunctions = bestFunctions[:0]
+			}
+			if score == best {
+				bestFunctions = append(bestFunctions, fn)
+			}
+		}
+
+		match := MethodMatch{Method: method, Candidates: distinctOffsets(bestFunctions)}
+		if len(match.Candidates) == 1 {
+			fn := match.Candidates[0]
+			match.Match = &fn
+		}
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+func scoreCandidate(name, service string) int {
+	qualifier := strings.ToLower(strings.TrimSuffix(name, lastComponent(name)))
+	score := 0
+	if shortService := strings.ToLower(service[strings.LastIndex(service, ".")+1:]); strings.Contains(qualifier, shortService) {
+		score += 2
+	}
+	for _, pkg := range strings.Split(service, ".") {
+		if len(pkg) > 0 && strings.Contains(qualifier, strings.ToLower(pkg)) {
+			score++
+		}
+	}
+	// Base class of the C++ service returns UNIMPLEMENTED, the handler is the overriding one
+	if strings.HasSuffix(qualifier, "::service::") {
+		score -= 5
+	}
+	return score
+}
+
+// isGenerated tells if the function belongs to a generated client stub or default implementation
+func isGenerated(name string) bool {
+	qualifier := strings.ToLower(strings.TrimSuffix(name, lastComponent(name)))
+	for _, generated := range generatedQualifiers {
+		if strings.Contains(qualifier, strings.ToLower(generated)) {
+			return true
+		}
+	}
+	return false
+}
+
+// distinctOffsets keeps one name per function, preferring the qualified names over the symbol names
+func distinctOffsets(functions []Function) []Function {
+	byOffset := make(map[uint64]Function, len(functions))
+	for _, fn := range functions {
+		if current, ok := byOffset[fn.Offset]; !ok || (strings.HasPrefix(current.Name, "_Z") && !strings.HasPrefix(fn.Name, "_Z")) {
+			byOffset[fn.Offset] = fn
+		}
+	}
+	distinct := make([]Function, 0, len(byOffset))
+	for _, fn := range byOffset {
+		distinct = append(distinct, fn)
+	}
+	sort.Slice(distinct, func(i, j int) bool { return distinct[i].Offset < distinct[j].Offset })
+	return distinct
+}
+
+// lastComponent returns the unqualified function name from the C++, Rust or Go name
+func lastComponent(name string) string {
+	name = normalizeName(name)
+	if idx := strings.LastIndex(name, "::"); idx >= 0 {
+		name = name[idx+2:]
+	}
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		name = name[idx+1:]
+	}
+	return name
+}
+
+// splitMethod splits "/pkg.Service/Method" into the service and the method name
+func splitMethod(method string) (string, string) {
+	method = strings.TrimPrefix(method, "/")
+	if idx := strings.LastIndex(method, "/"); idx >= 0 {
+		return method[:idx], method[idx+1:]
+	}
+	return "", method
+}
+
+func snakeCase(name string) string {
+	var sb strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				sb.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,316 @@
+package symbol
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Symbol is the source location of the address
+type Symbol struct {
+	Function string
+	File     string
+	Line     int
+}
+
+type function struct {
+	name string
+	low  uint64
+	high uint64
+}
+
+type lineEntry struct {
+	address uint64
+	file    string
+	line    int
+	end     bool
+}
+
+// Symbolizer resolves the names to the offsets and the offsets to the source locations of a single ELF module.
+// Offsets are relative to the module base, same as the offsets used by the instrumentation and the sanitizers.
+type Symbolizer struct {
+	Path      string
+	base      uint64
+	functions []function
+	lines     []lineEntry
+}
+
+// Open reads the symbol tables and the DWARF debug information of the module. Modules without the
+// debug information are still symbolized to the function names from the symbol tables.
+func Open(path string) (*Symbolizer, error) {
+	file, err := elf.Open(path)
+	if err != nil {
+		return nil, errors.Errorf("Failed to open the module %s: %s", path, err)
+	}
+	defer file.Close()
+
+	s := &Symbolizer{Path: path, base: loadBase(file)}
+	s.readSymbolTables(file)
+	if data, err := file.DWARF(); err == nil {
+		s.readDWARF(data)
+	}
+	if len(s.functions) == 0 {
+		return nil, errors.Errorf("Module %s has no symbols!", path)
+	}
+
+	sort.SliceStable(s.functions, func(i, j int) bool {
+		return s.functions[i].low < s.functions[j].low
+	})
+	sort.SliceStable(s.lines, func(i, j int) bool {
+		return s.lines[i].address < s.lines[j].address
+	})
+	return s, nil
+}
+
+// ResolveName returns the offset of the function. Name can be the symbol name or the qualified
+// name (e.g. "ns::Service::Method"), the parameter list is ignored.
+func (s *Symbolizer) ResolveName(name string) (uint64, error) {
+	wanted := normalizeName(name)
+	var found *function
+	for i := range s.functions {
+		if s.functions[i].low == 0 || normalizeName(s.functions[i].name) != wanted {
+			continue
+		}
+		if found != nil && found.low != s.functions[i].low {
+			return 0, errors.Errorf("Function name %s is ambiguous in %s!", name, s.Path)
+		}
+		found = &s.functions[i]
+	}
+	if found == nil {
+		return 0, errors.Errorf("Function %s was not found in %s!", name, s.Path)
+	}
+	return found.low - s.base, nil
+}
+
+// Function is the named function of the module
+type Function struct {
+	Name   string
+	Offset uint64
+}
+
+// Functions returns every known name of the functions. The same function can be listed under the
+// symbol name and the qualified name.
+func (s *Symbolizer) Functions() []Function {
+	seen := make(map[Function]bool, len(s.functions))
+	functions := make([]Function, 0, len(s.functions))
+	for _, fn := range s.functions {
+		f := Function{Name: fn.name, Offset: fn.low - s.base}
+		if !seen[f] {
+			seen[f] = true
+			functions = append(functions, f)
+		}
+	}
+	return functions
+}
+
+// Symbolize returns the location of the offset. Offsets bigger than the base of the non-PIE
+// executable are treated as the absolute addresses.
+func (s *Symbolizer) Symbolize(offset uint64) (Symbol, bool) {
+	address := offset
+	if s.base == 0 || offset < s.base {
+		address = offset + s.base
+	}
+
+	var sym Symbol
+	if fn := s.findFunction(address); fn != nil {
+		sym.Function = fn.name
+	}
+	// Last line table row before the address, unless it ends the sequence
+	idx := sort.Search(len(s.lines), func(i int) bool { return s.lines[i].address > address }) - 1
+	if idx >= 0 && !s.lines[idx].end {
+		sym.File = s.lines[idx].file
+		sym.Line = s.lines[idx].line
+	}
+	return sym, len(sym.Function) > 0 || len(sym.File) > 0
+}
+
+func (s *Symbolizer) findFunction(address uint64) *function {
+	idx := sort.Search(len(s.functions), func(i int) bool { return s.functions[i].low > address })
+	// Nested ranges (e.g. inlined code) come after the outer one, so the closest start is checked first
+	for i := idx - 1; i >= 0; i-- {
+		fn := &s.functions[i]
+		if address >= fn.low && address < fn.high {
+			return fn
+		}
+		if address-fn.low > 1<<20 {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *Symbolizer) readSymbolTables(file *elf.File) {
+	symbols, _ := file.Symbols()
+	dynSymbols, _ := file.DynamicSymbols()
+	for _, sym := range append(symbols, dynSymbols...) {
+		if elf.ST_TYPE(sym.Info) != elf.STT_FUNC || sym.Value == 0 || len(sym.Name) == 0 {
+			continue
+		}
+		size := sym.Size
+		if size == 0 {
+			size = 1
+		}
+		s.functions = append(s.functions, function{name: sym.Name, low: sym.Value, high: sym.Value + size})
+		// Demangled name allows matching the modules without the debug information by the qualified name
+		if demangled, ok := demangle(sym.Name); ok {
+			s.functions = append(s.functions, function{name: demangled, low: sym.Value, high: sym.Value + size})
+		}
+	}
+}
+
+// readDWARF reads the functions with the names qualified by the enclosing namespaces and classes,
+// and the line tables of all compilation units.
+func (s *Symbolizer) readDWARF(data *dwarf.Data) {
+	names := make(map[dwarf.Offset]string)
+	type pending struct {
+		entry  *dwarf.Entry
+		ranges [][2]uint64
+	}
+	subprograms := make([]pending, 0, 256)
+	scopes := make([]string, 0, 8)
+	// Every entry with children opens the scope, which ends with the null entry
+	scopeOpen := make([]bool, 0, 8)
+
+	reader := data.Reader()
+	for {
+		entry, err := reader.Next()
+		if err != nil || entry == nil {
+			break
+		}
+		if entry.Tag == 0 {
+			if n := len(scopeOpen); n > 0 {
+				if scopeOpen[n-1] {
+					scopes = scopes[:len(scopes)-1]
+				}
+				scopeOpen = scopeOpen[:n-1]
+			}
+			continue
+		}
+
+		if entry.Tag == dwarf.TagCompileUnit {
+			s.readLines(data, entry)
+		}
+
+		name, _ := entry.Val(dwarf.AttrName).(string)
+		if len(name) > 0 {
+			names[entry.Offset] = qualify(scopes, name)
+		}
+		if entry.Tag == dwarf.TagSubprogram {
+			if ranges, err := data.Ranges(entry); err == nil && len(ranges) > 0 {
+				subprograms = append(subprograms, pending{entry: entry, ranges: ranges})
+			}
+		}
+
+		if entry.Children {
+			isScope := isScopeTag(entry.Tag) && len(name) > 0
+			if isScope {
+				scopes = append(scopes, name)
+			}
+			scopeOpen = append(scopeOpen, isScope)
+		}
+	}
+
+	// Definitions outside the class refer to the declaration, which has the qualified name
+	for _, sub := range subprograms {
+		name := names[sub.entry.Offset]
+		for _, attr := range []dwarf.Attr{dwarf.AttrSpecification, dwarf.AttrAbstractOrigin} {
+			if ref, ok := sub.entry.Val(attr).(dwarf.Offset); ok && len(names[ref]) > 0 {
+				name = names[ref]
+				break
+			}
+		}
+		if len(name) == 0 {
+			continue
+		}
+		for _, r := range sub.ranges {
+			s.functions = append(s.functions, function{name: name, low: r[0], high: r[1]})
+		}
+	}
+}
+
+func (s *Symbolizer) readLines(data *dwarf.Data, unit *dwarf.Entry) {
+	lineReader, err := data.LineReader(unit)
+	if err != nil || lineReader == nil {
+		return
+	}
+	var line dwarf.LineEntry
+	for lineReader.Next(&line) == nil {
+		entry := lineEntry{address: line.Address, line: line.Line, end: line.EndSequence}
+		if line.File != nil {
+			entry.file = line.File.Name
+		}
+		s.lines = append(s.lines, entry)
+	}
+}
+
+func isScopeTag(tag dwarf.Tag) bool {
+	switch tag {
+	case dwarf.TagNamespace, dwarf.TagClassType, dwarf.TagStructType, dwarf.TagUnionType:
+		return true
+	}
+	return false
+}
+
+func qualify(scopes []string, name string) string {
+	if len(scopes) == 0 {
+		return name
+	}
+	return strings.Join(scopes, "::") + "::" + name
+}
+
+// Go method receiver, e.g. the "(*server)" of "main.(*server).Method"
+var goReceiver = regexp.MustCompile(`\.\(\*?([^()]+)\)\.`)
+
+// normalizeName removes the trailing parameter list, the parentheses of the Go receivers and the spaces,
+// so "ns::Foo(int, char*) const" matches "ns::Foo" and "main.(*server).Foo" matches "main.server.Foo"
+func normalizeName(name string) string {
+	name = strings.TrimSuffix(strings.TrimSpace(name), " const")
+	if idx := parameterListStart(name); idx > 0 {
+		name = name[:idx]
+	}
+	name = goReceiver.ReplaceAllString(name, ".$1.")
+	return strings.ReplaceAll(name, " ", "")
+}
+
+// parameterListStart returns the index of the parenthesis which opens the trailing parameter list,
+// or -1 if the name does not end with one. Parameters can have parentheses too, e.g. "Foo(void (*)(int))".
+func parameterListStart(name string) int {
+	if !strings.HasSuffix(name, ")") {
+		return -1
+	}
+	depth := 0
+	for i := len(name) - 1; i >= 0; i-- {
+		switch name[i] {
+		case ')':
+			depth++
+		case '(':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// loadBase is the lowest address of the loaded segments, which is zero for the PIE executables and the libraries
+func loadBase(file *elf.File) uint64 {
+	base := ^uint64(0)
+	for _, prog := range file.Progs {
+		if prog.Type != elf.PT_LOAD || prog.Vaddr >= base {
+			continue
+		}
+		base = prog.Vaddr
+		if prog.Align > 1 {
+			base &^= prog.Align - 1
+		}
+	}
+	if base == ^uint64(0) {
+		return 0
+	}
+	return base
+}
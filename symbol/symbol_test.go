@@ -0,0 +1,65 @@
+package symbol
+
+import "testing"
+
+func TestNormalizeName(t *testing.T) {
+	names := map[string]string{
+		"test::TestServiceImpl::MethodOneBad(grpc::ServerContext*, test::Request const*, test::Reply*)": "test::TestServiceImpl::MethodOneBad",
+		"ns::Foo(void (*)(int)) const":                 "ns::Foo",
+		"main.(*testServiceServer).MethodOneBad":       "main.testServiceServer.MethodOneBad",
+		"main.(testServiceServer).MethodOneBad":        "main.testServiceServer.MethodOneBad",
+		"main.testServiceServer.MethodOneBad":          "main.testServiceServer.MethodOneBad",
+		"server::TestService::method_one_bad":          "server::TestService::method_one_bad",
+		"main.(*testServiceServer).MethodOneBad.func1": "main.testServiceServer.MethodOneBad.func1",
+	}
+	for name, want := range names {
+		if got := normalizeName(name); got != want {
+			t.Errorf("normalizeName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestMatchGoMethod(t *testing.T) {
+	s := &Symbolizer{
+		Path: "server",
+		functions: []function{
+			{name: "main.main", low: 0x1000, high: 0x1100},
+			{name: "main.(*testServiceServer).MethodOneBad", low: 0x2000, high: 0x2100},
+			{name: "main.(*testServiceServer).MethodTwo", low: 0x3000, high: 0x3100},
+			{name: "test.(*testServiceClient).MethodOneBad", low: 0x4000, high: 0x4100},
+		},
+	}
+
+	matches := s.MatchMethods([]string{"test.testService/MethodOneBad"})
+	if len(matches) != 1 || matches[0].Match == nil {
+		t.Fatalf("Go handler was not matched: %+v", matches)
+	}
+	if matches[0].Match.Offset != 0x2000 {
+		t.Errorf("Matched %s at 0x%x, want the server method at 0x2000", matches[0].Match.Name, matches[0].Match.Offset)
+	}
+
+	for _, name := range []string{"main.(*testServiceServer).MethodOneBad", "main.testServiceServer.MethodOneBad"} {
+		offset, err := s.ResolveName(name)
+		if err != nil {
+			t.Fatalf("ResolveName(%q): %s", name, err)
+		}
+		if offset != 0x2000 {
+			t.Errorf("ResolveName(%q) = 0x%x, want 0x2000", name, offset)
+		}
+	}
+}
+
+func TestMatchSkipsClientStubs(t *testing.T) {
+	s := &Symbolizer{
+		Path: "server",
+		functions: []function{
+			{name: "test.(*testServiceClient).MethodOneBad", low: 0x4000, high: 0x4100},
+			{name: "test::TestService::Stub::MethodOneBad", low: 0x5000, high: 0x5100},
+		},
+	}
+
+	matches := s.MatchMethods([]string{"test.testService/MethodOneBad"})
+	if len(matches) != 1 || matches[0].Match != nil || len(matches[0].Candidates) != 0 {
+		t.Errorf("Client stubs were matched: %+v", matches)
+	}
+}
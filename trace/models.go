@@ -10,3 +10,23 @@ type RPCCoverage struct {
 	Module   string     `json:"module"`
 	Coverage [][]string `json:"coverage"`
 }
+
+type RPCModuleCoverage struct {
+	Calls  []string   `json:"calls"`
+	Blocks []string   `json:"blocks"`
+	Edges  [][]string `json:"edges"`
+}
+
+// ModuleCoverage is the module wide coverage with the offsets relative to the module base
+type ModuleCoverage struct {
+	Calls  []uint64
+	Blocks []uint64
+	Edges  []CallEdge
+}
+
+// CallEdge is the call of the module function. Functions called from outside of the module have no caller.
+type CallEdge struct {
+	Caller    uint64
+	HasCaller bool
+	Callee    uint64
+}
@@ -31,6 +31,9 @@ type Trace struct {
 	device  *frida_go.Device
 	script  *frida_go.Script
 	session *frida_go.Session
+	// ResolvePid returns the PID of the target or 0 if it is not known, so the session follows the restarted process
+	ResolvePid func() int
+	pid        uint
 }
 
 func NewTraceManager() (*Trace, error) {
@@ -53,23 +56,89 @@ func NewTraceManager() (*Trace, error) {
 }
 
 func (t *Trace) Start(pName string, handler config.Handler) error {
-	p, err := t.device.GetProcessByName(pName, frida_go.ProcessMatchOptions{})
+	if err := t.load(pName); err != nil {
+		return err
+	}
+
+	r, err := t.sendRpcCall("setTarget", handler)
+	if err != nil || r != "true" {
+		return errors.Errorf("Failed to set the coverage target: %s", err)
+	}
+
+	_, err = t.sendRpcCall("startCoverageFeed")
 	if err != nil {
-		return errors.Errorf("Failed to find specified process: %s", err)
+		t.Stop()
+		return errors.Errorf("Failed to start the coverage feed: %s", err)
 	}
 
-	if t.session == nil {
-		t.session, err = t.device.Attach(p.Pid(), frida_go.SessionOptions{})
-		if err != nil {
-			return errors.Errorf("Failed to attach to the specifed process: %s", err)
-		}
+	return nil
+}
+
+// StartModule traces the calls and the basic blocks of the whole module on all threads of the process
+func (t *Trace) StartModule(pName string, module string) error {
+	if err := t.load(pName); err != nil {
+		return err
+	}
+
+	if _, err := t.sendRpcCall("startModuleCoverage", module); err != nil {
+		t.Stop()
+		return errors.Errorf("Failed to start the module coverage: %s", err)
+	}
+	return nil
+}
+
+// GetModuleCoverage returns the offsets of the called functions in the order of the first call, the executed blocks
+// and the calls between the functions
+func (t *Trace) GetModuleCoverage() (*ModuleCoverage, error) {
+	r, err := t.sendRpcCall("getModuleCoverage")
+	if err != nil {
+		return nil, errors.Errorf("Failed to fetch module coverage: %s", err)
+	}
+	rpcCov := RPCModuleCoverage{}
+	if err := json.Unmarshal([]byte(r), &rpcCov); err != nil {
+		return nil, errors.Errorf("Failed to unmarshal module coverage: %s", err)
+	}
+
+	return &ModuleCoverage{
+		Calls:  parseOffsets(rpcCov.Calls),
+		Blocks: parseOffsets(rpcCov.Blocks),
+		Edges:  parseEdges(rpcCov.Edges),
+	}, nil
+}
+
+func (t *Trace) ClearModuleCoverage() error {
+	if _, err := t.sendRpcCall("clearModuleCoverage"); err != nil {
+		return errors.Errorf("Failed to clear module coverage: %s", err)
+	}
+	return nil
+}
+
+func (t *Trace) StopModule() error {
+	if _, err := t.sendRpcCall("stopModuleCoverage"); err != nil {
+		return errors.Errorf("Failed to stop module coverage: %s", err)
+	}
+	return t.Unload()
+}
+
+func (t *Trace) load(pName string) error {
+	pid, err := t.targetPid(pName)
+	if err != nil {
+		return err
 	}
 
-	if t.session != nil && t.session.IsDetached() {
-		t.session, err = t.device.Attach(p.Pid(), frida_go.SessionOptions{})
+	if t.session != nil && !t.session.IsDetached() && t.pid != pid {
+		// Session of the previous process is left when the target was restarted with a new PID
+		t.session.Detach()
+		t.session.Free()
+		t.session = nil
+	}
+
+	if t.session == nil || t.session.IsDetached() {
+		t.session, err = t.device.Attach(pid, frida_go.SessionOptions{})
 		if err != nil {
 			return errors.Errorf("Failed to attach to the specifed process: %s", err)
 		}
+		t.pid = pid
 	}
 
 	scops := frida_go.ScriptOptions{
@@ -93,19 +162,21 @@ func (t *Trace) Start(pName string, handler config.Handler) error {
 	if err != nil {
 		return errors.Errorf("Failed to load the script: %s", err)
 	}
+	return nil
+}
 
-	r, err := t.sendRpcCall("setTarget", handler)
-	if err != nil || r != "true" {
-		return errors.Errorf("Failed to set the coverage target: %s", err)
+func (t *Trace) targetPid(pName string) (uint, error) {
+	if t.ResolvePid != nil {
+		if pid := t.ResolvePid(); pid != 0 {
+			return uint(pid), nil
+		}
 	}
 
-	_, err = t.sendRpcCall("startCoverageFeed")
+	p, err := t.device.GetProcessByName(pName, frida_go.ProcessMatchOptions{})
 	if err != nil {
-		t.Stop()
-		return errors.Errorf("Failed to start the coverage feed: %s", err)
+		return 0, errors.Errorf("Failed to find specified process: %s", err)
 	}
-
-	return nil
+	return p.Pid(), nil
 }
 
 func (t *Trace) GetCoverage() ([]CoverageBlock, error) {
@@ -196,7 +267,8 @@ func (t *Trace) Cleanup() error {
 }
 
 func (t *Trace) sendRpcCall(methodName string, args ...interface{}) (string, error) {
-	ctx, _ := context.WithTimeout(context.TODO(), time.Second*5)
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second*5)
+	defer cancel()
 	r, err := t.script.RpcCall(ctx, methodName, args)
 	if err != nil {
 		return "", errors.Errorf("Failed to call %s: %s ", methodName, err)
@@ -204,3 +276,35 @@ func (t *Trace) sendRpcCall(methodName string, args ...interface{}) (string, err
 
 	return r.ToString(), nil
 }
+
+func parseOffsets(values []string) []uint64 {
+	offsets := make([]uint64, 0, len(values))
+	for _, value := range values {
+		offset, err := strconv.ParseUint(value, 0, 64)
+		if err != nil {
+			continue
+		}
+		offsets = append(offsets, offset)
+	}
+	return offsets
+}
+
+func parseEdges(values [][]string) []CallEdge {
+	edges := make([]CallEdge, 0, len(values))
+	for _, value := range values {
+		if len(value) != 2 {
+			continue
+		}
+		callee, err := strconv.ParseUint(value[1], 0, 64)
+		if err != nil {
+			continue
+		}
+		edge := CallEdge{Callee: callee}
+		if caller, err := strconv.ParseUint(value[0], 0, 64); err == nil {
+			edge.Caller = caller
+			edge.HasCaller = true
+		}
+		edges = append(edges, edge)
+	}
+	return edges
+}
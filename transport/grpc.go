@@ -0,0 +1,56 @@
+package transport
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/lukjok/gipcfuzz/communication"
+	"github.com/lukjok/gipcfuzz/config"
+	"github.com/lukjok/gipcfuzz/util"
+)
+
+// GRPC sends the messages as gRPC, gRPC-Web or Connect calls
+type GRPC struct {
+	Endpoint          string
+	ProtoFiles        []string
+	ProtoIncludesPath []string
+	Protocol          string
+	UseHTTP2          bool
+	Timeout           time.Duration
+}
+
+func NewGRPC(settings config.Configuration) *GRPC {
+	return &GRPC{
+		Endpoint:          util.GetTargetEndpoint(settings),
+		ProtoFiles:        util.GetFileFullPathInDirectory(settings.ProtoFilesPath, []string{"Includes"}),
+		ProtoIncludesPath: settings.ProtoFilesIncludePath,
+		Protocol:          settings.Protocol,
+		UseHTTP2:          settings.UseHTTP2,
+	}
+}
+
+func (g *GRPC) Send(path string, data []byte) (proto.Message, error) {
+	return g.send(path, data, g.Timeout, 0)
+}
+
+func (g *GRPC) SendWithTimeout(path string, data []byte, timeout time.Duration) (proto.Message, error) {
+	return g.send(path, data, timeout, timeout)
+}
+
+func (g *GRPC) send(path string, data []byte, timeout time.Duration, dialTimeout time.Duration) (proto.Message, error) {
+	return communication.SendRequestWithMessage(communication.GIPCRequest{
+		Endpoint:          g.Endpoint,
+		Path:              path,
+		Data:              data,
+		ProtoFiles:        g.ProtoFiles,
+		ProtoIncludesPath: g.ProtoIncludesPath,
+		Protocol:          g.Protocol,
+		UseHTTP2:          g.UseHTTP2,
+		Timeout:           timeout,
+		DialTimeout:       dialTimeout,
+	})
+}
+
+func (g *GRPC) SetTimeout(timeout time.Duration) {
+	g.Timeout = timeout
+}
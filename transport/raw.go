@@ -0,0 +1,124 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/lukjok/gipcfuzz/communication"
+	"github.com/lukjok/gipcfuzz/config"
+	"github.com/lukjok/gipcfuzz/util"
+	"github.com/pkg/errors"
+)
+
+const (
+	rawDialTimeout = 10 * time.Second
+	rawIOTimeout   = 10 * time.Second
+	// Responses bigger than this are treated as a broken framing
+	rawMaxResponseSize = 64 << 20
+)
+
+// Raw sends varint length-delimited protobuf messages over a TCP or Unix socket without any RPC layer.
+// The message types are taken from the configuration, since the stream itself does not carry them.
+type Raw struct {
+	Network      string
+	Address      string
+	ResponseType *desc.MessageDescriptor
+	// Timeout of the whole exchange, rawIOTimeout is used when it is not set
+	Timeout time.Duration
+}
+
+func NewRaw(settings config.Configuration) (*Raw, error) {
+	network, address, err := communication.ParseTarget(util.GetTargetEndpoint(settings))
+	if err != nil {
+		return nil, err
+	}
+
+	protoFiles := util.GetFileFullPathInDirectory(settings.ProtoFilesPath, []string{"Includes"})
+	source, err := communication.DescriptorSourceFromProtoFiles(settings.ProtoFilesIncludePath, protoFiles...)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Failed to process proto source files")
+	}
+
+	raw := &Raw{
+		Network: network,
+		Address: address,
+	}
+	if len(settings.RawResponseType) > 0 {
+		dsc, err := source.FindSymbol(settings.RawResponseType)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "Failed to find the raw response type %s", settings.RawResponseType)
+		}
+		msgDsc, ok := dsc.(*desc.MessageDescriptor)
+		if !ok {
+			return nil, errors.Errorf("Raw response type %s is not a message", settings.RawResponseType)
+		}
+		raw.ResponseType = msgDsc
+	}
+	return raw, nil
+}
+
+// Send writes the framed message and waits for the framed response. If no response type
+// is configured, the message is only written and nil response is returned.
+func (r *Raw) Send(path string, data []byte) (proto.Message, error) {
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = rawIOTimeout
+	}
+	return r.send(data, rawDialTimeout, timeout)
+}
+
+func (r *Raw) SendWithTimeout(path string, data []byte, timeout time.Duration) (proto.Message, error) {
+	return r.send(data, timeout, timeout)
+}
+
+func (r *Raw) send(data []byte, dialTimeout time.Duration, timeout time.Duration) (proto.Message, error) {
+	conn, err := net.DialTimeout(r.Network, r.Address, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(lenBuf, uint64(len(data)))
+	if _, err := conn.Write(append(lenBuf[:n], data...)); err != nil {
+		return nil, err
+	}
+
+	if r.ResponseType == nil {
+		return nil, nil
+	}
+
+	reader := bufio.NewReader(conn)
+	resLen, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	if resLen > rawMaxResponseSize {
+		return nil, errors.Errorf("Response length %d is too big", resLen)
+	}
+
+	resBuf := make([]byte, resLen)
+	if _, err := io.ReadFull(reader, resBuf); err != nil {
+		return nil, err
+	}
+
+	response := dynamic.NewMessage(r.ResponseType)
+	if err := response.Unmarshal(resBuf); err != nil {
+		return nil, errors.WithMessage(err, "Failed to unmarshal the response")
+	}
+	return response, nil
+}
+
+func (r *Raw) SetTimeout(timeout time.Duration) {
+	r.Timeout = timeout
+}
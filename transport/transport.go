@@ -0,0 +1,35 @@
+package transport
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/lukjok/gipcfuzz/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	GRPCTransport = "grpc"
+	RawTransport  = "raw"
+)
+
+// Transport delivers a single serialized message to the fuzzed application and returns the decoded response
+type Transport interface {
+	Send(path string, data []byte) (proto.Message, error)
+	// SendWithTimeout sends the message with its own deadline, which limits the connection too
+	SendWithTimeout(path string, data []byte, timeout time.Duration) (proto.Message, error)
+	// SetTimeout sets the deadline of each call
+	SetTimeout(timeout time.Duration)
+}
+
+// NewTransport creates the transport selected in the configuration
+func NewTransport(settings config.Configuration) (Transport, error) {
+	switch settings.Transport {
+	case "", GRPCTransport:
+		return NewGRPC(settings), nil
+	case RawTransport:
+		return NewRaw(settings)
+	default:
+		return nil, errors.Errorf("Unknown transport: %s", settings.Transport)
+	}
+}
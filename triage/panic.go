@@ -0,0 +1,240 @@
+package triage
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lukjok/gipcfuzz/output"
+)
+
+var (
+	// panic: runtime error: index out of range [5] with length 3
+	// fatal error: concurrent map writes
+	goPanicRe = regexp.MustCompile(`^(panic|fatal error): (.*?)(?: \[recovered\])?$`)
+	// [signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x4553a9]
+	goSignalRe = regexp.MustCompile(`^\[signal (\w+).*? addr=(0x[0-9a-fA-F]+)`)
+	// goroutine 1 [running]:
+	goRoutineRe = regexp.MustCompile(`^goroutine \d+ \[.*\]:$`)
+	// 	/src/main.go:12 +0x1d
+	goFileRe = regexp.MustCompile(`^\s+(.+\.go):(\d+)(?: \+(0x[0-9a-fA-F]+))?`)
+
+	// thread 'main' panicked at 'index out of bounds: the len is 3 but the index is 5', src/main.rs:4:5
+	rustOldPanicRe = regexp.MustCompile(`^thread '(.*)' panicked at '(.*)', (.+?):(\d+)(?::\d+)?$`)
+	// thread 'main' panicked at src/main.rs:4:5:
+	rustPanicRe = regexp.MustCompile(`^thread '(.*)' panicked at (.+?):(\d+)(?::\d+)?:$`)
+	//    3: app::handler
+	rustFrameRe = regexp.MustCompile(`^\s*(\d+):\s+(?:(0x[0-9a-fA-F]+) - )?(.+)$`)
+	//              at ./src/main.rs:4:5
+	rustAtRe = regexp.MustCompile(`^\s+at (.+?):(\d+)(?::\d+)?$`)
+)
+
+// Go runtime panics have fixed messages, custom panics are reported as "panic"
+var goBugTypes = []struct {
+	prefix  string
+	bugType string
+}{
+	{"runtime error: index out of range", "index-out-of-range"},
+	{"runtime error: slice bounds out of range", "slice-bounds-out-of-range"},
+	{"runtime error: invalid memory address or nil pointer dereference", "nil-pointer-dereference"},
+	{"runtime error: integer divide by zero", "integer-divide-by-zero"},
+	{"runtime error: makeslice", "makeslice-out-of-range"},
+	{"runtime error: hash of unhashable type", "unhashable-map-key"},
+	{"assignment to entry in nil map", "nil-map-write"},
+	{"interface conversion", "interface-conversion"},
+	{"concurrent map writes", "concurrent-map-writes"},
+	{"concurrent map read and map write", "concurrent-map-read-write"},
+	{"concurrent map iteration and map write", "concurrent-map-iteration-write"},
+	{"runtime: out of memory", "out-of-memory"},
+	{"stack overflow", "stack-overflow"},
+	{"all goroutines are asleep - deadlock!", "deadlock"},
+}
+
+var rustBugTypes = []struct {
+	prefix  string
+	bugType string
+}{
+	{"index out of bounds", "index-out-of-bounds"},
+	{"range end index", "slice-index-out-of-range"},
+	{"range start index", "slice-index-out-of-range"},
+	{"byte index", "str-index-out-of-range"},
+	{"called `Option::unwrap()` on a `None` value", "unwrap-on-none"},
+	{"called `Result::unwrap()` on an `Err` value", "unwrap-on-err"},
+	{"attempt to add with overflow", "integer-overflow"},
+	{"attempt to subtract with overflow", "integer-overflow"},
+	{"attempt to multiply with overflow", "integer-overflow"},
+	{"attempt to negate with overflow", "integer-overflow"},
+	{"attempt to shift left with overflow", "integer-overflow"},
+	{"attempt to shift right with overflow", "integer-overflow"},
+	{"attempt to divide by zero", "divide-by-zero"},
+	{"attempt to calculate the remainder with a divisor of zero", "divide-by-zero"},
+	{"capacity overflow", "capacity-overflow"},
+	{"already borrowed", "already-borrowed"},
+	{"already mutably borrowed", "already-borrowed"},
+}
+
+// parseGoPanic handles the Go panics and fatal runtime errors with the goroutine traceback
+func parseGoPanic(lines []string) *Report {
+	start := -1
+	var report *Report
+	for i, line := range lines {
+		if m := goPanicRe.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			report = &Report{
+				Tool:    GoRuntime,
+				BugType: "panic",
+				Summary: strings.TrimSpace(line),
+			}
+			for _, bt := range goBugTypes {
+				if strings.HasPrefix(m[2], bt.prefix) {
+					report.BugType = bt.bugType
+					break
+				}
+			}
+			start = i + 1
+			break
+		}
+	}
+	if report == nil {
+		return nil
+	}
+
+	// Only the first goroutine is the one which has panicked
+	inStack := false
+	for i := start; i < len(lines); i++ {
+		line := lines[i]
+		if m := goSignalRe.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			report.Address = m[2]
+			continue
+		}
+		if goRoutineRe.MatchString(strings.TrimSpace(line)) {
+			if inStack {
+				break
+			}
+			inStack = true
+			continue
+		}
+		if !inStack {
+			continue
+		}
+		if len(strings.TrimSpace(line)) == 0 {
+			break
+		}
+
+		// Every frame is the function line followed by the indented location line
+		if strings.HasPrefix(line, "\t") || strings.HasPrefix(line, " ") {
+			continue
+		}
+		frame := output.StackFrame{
+			Index:    len(report.Frames),
+			Function: goFunctionName(line),
+		}
+		if i+1 < len(lines) {
+			if m := goFileRe.FindStringSubmatch(lines[i+1]); m != nil {
+				frame.File = m[1]
+				frame.Line, _ = strconv.Atoi(m[2])
+				frame.Offset = m[3]
+				i++
+			}
+		}
+		report.Frames = append(report.Frames, frame)
+	}
+	return report
+}
+
+// goFunctionName strips the arguments, e.g. "main.handler(0xc000010000, 0x5)" becomes "main.handler"
+func goFunctionName(line string) string {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "created by ") {
+		line = strings.TrimPrefix(line, "created by ")
+		if idx := strings.Index(line, " in goroutine"); idx > 0 {
+			line = line[:idx]
+		}
+		return line
+	}
+	if idx := strings.LastIndex(line, "("); idx > 0 {
+		return line[:idx]
+	}
+	return line
+}
+
+// parseRustPanic handles the Rust panics in both the old and new message format. The stack is
+// only available when the target runs with RUST_BACKTRACE set.
+func parseRustPanic(lines []string) *Report {
+	var report *Report
+	start := -1
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		var message, file, lineNo string
+		if m := rustOldPanicRe.FindStringSubmatch(trimmed); m != nil {
+			message, file, lineNo = m[2], m[3], m[4]
+		} else if m := rustPanicRe.FindStringSubmatch(trimmed); m != nil {
+			file, lineNo = m[2], m[3]
+			if i+1 < len(lines) {
+				message = strings.TrimSpace(lines[i+1])
+			}
+		} else {
+			continue
+		}
+
+		report = &Report{
+			Tool:    RustRuntime,
+			BugType: "panic",
+			Summary: strings.TrimSpace(message),
+		}
+		for _, bt := range rustBugTypes {
+			if strings.HasPrefix(message, bt.prefix) {
+				report.BugType = bt.bugType
+				break
+			}
+		}
+		panicLine, _ := strconv.Atoi(lineNo)
+		report.Frames = []output.StackFrame{{Index: 0, File: file, Line: panicLine}}
+		start = i + 1
+		break
+	}
+	if report == nil {
+		return nil
+	}
+
+	frames := make([]output.StackFrame, 0, 10)
+	inStack := false
+	for i := start; i < len(lines); i++ {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), "stack backtrace:") {
+			inStack = true
+			continue
+		}
+		if !inStack {
+			continue
+		}
+		if m := rustAtRe.FindStringSubmatch(lines[i]); m != nil {
+			if len(frames) > 0 {
+				frames[len(frames)-1].File = m[1]
+				frames[len(frames)-1].Line, _ = strconv.Atoi(m[2])
+			}
+			continue
+		}
+		m := rustFrameRe.FindStringSubmatch(lines[i])
+		if m == nil {
+			break
+		}
+		index, _ := strconv.Atoi(m[1])
+		frames = append(frames, output.StackFrame{
+			Index:    index,
+			Address:  m[2],
+			Function: rustFunctionName(m[3]),
+		})
+	}
+	if len(frames) > 0 {
+		report.Frames = frames
+	}
+	return report
+}
+
+// rustFunctionName strips the symbol hash, e.g. "app::handler::h1a2b3c4d5e6f7a8b" becomes "app::handler"
+func rustFunctionName(name string) string {
+	name = strings.TrimSpace(name)
+	if idx := strings.LastIndex(name, "::h"); idx > 0 && len(name)-idx == 19 {
+		return name[:idx]
+	}
+	return name
+}
@@ -0,0 +1,163 @@
+package triage
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lukjok/gipcfuzz/output"
+)
+
+var (
+	// ==1234==ERROR: AddressSanitizer: heap-buffer-overflow on address 0x602000000011 at pc ...
+	sanitizerHeaderRe = regexp.MustCompile(`^(?:==\d+==)?(?:ERROR|WARNING): (AddressSanitizer|MemorySanitizer|ThreadSanitizer|LeakSanitizer): (.+?)(?: on (?:unknown address |address )?(0x[0-9a-fA-F]+).*| \(pid=\d+\).*)?$`)
+	// READ of size 4 at 0x602000000011 thread T0
+	accessRe = regexp.MustCompile(`^\s*(READ|WRITE|Read|Write|Atomic read|Atomic write|Previous read|Previous write) of size (\d+) at (0x[0-9a-fA-F]+)`)
+	// ==1==The signal is caused by a READ memory access.
+	signalAccessRe = regexp.MustCompile(`The signal is caused by a (READ|WRITE) memory access`)
+	// #0 0x4f5a3c in foo(char*) /src/foo.cc:12:3
+	// #1 0x7f0000 in foo (/lib/libfoo.so+0x1234)
+	// #0 foo /src/foo.cc:12:3 (binary+0x4a)      <- ThreadSanitizer has no pc
+	frameRe = regexp.MustCompile(`^\s*#(\d+)\s+(?:(0x[0-9a-fA-F]+)\s+)?(?:in\s+)?(.*)$`)
+	// /src/foo.cc:12:3
+	fileLineRe = regexp.MustCompile(`^(.+?):(\d+)(?::\d+)?$`)
+	// (/lib/libfoo.so+0x1234)
+	moduleOffsetRe = regexp.MustCompile(`\(([^()]+)\+(0x[0-9a-fA-F]+)\)$`)
+	// SUMMARY: AddressSanitizer: heap-buffer-overflow /src/foo.cc:12:3 in foo(char*)
+	summaryRe = regexp.MustCompile(`^SUMMARY: (\w+): (.*)$`)
+	// /src/foo.cc:12:5: runtime error: signed integer overflow: 2147483647 + 1 cannot be represented in type 'int'
+	ubsanRe = regexp.MustCompile(`^(.+?):(\d+):(?:\d+:)? runtime error: (.*)$`)
+	// Numbers and addresses are not part of the bug type
+	numberRe = regexp.MustCompile(`(0x[0-9a-fA-F]+|\d+)`)
+)
+
+// parseSanitizerReport handles AddressSanitizer, MemorySanitizer, ThreadSanitizer and LeakSanitizer reports
+func parseSanitizerReport(lines []string) *Report {
+	start := -1
+	var report *Report
+	for i, line := range lines {
+		if m := sanitizerHeaderRe.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			report = &Report{
+				Tool:    m[1],
+				BugType: strings.ReplaceAll(strings.TrimSpace(m[2]), " ", "-"),
+				Address: m[3],
+			}
+			start = i + 1
+			break
+		}
+	}
+	if report == nil {
+		return nil
+	}
+
+	// Only the first stack belongs to the faulting access, the next ones are allocation and free stacks
+	inStack := false
+	for _, line := range lines[start:] {
+		trimmed := strings.TrimSpace(line)
+
+		if m := accessRe.FindStringSubmatch(trimmed); m != nil && len(report.AccessType) == 0 {
+			report.AccessType = strings.ToUpper(m[1])
+			report.AccessSize, _ = strconv.Atoi(m[2])
+			if len(report.Address) == 0 {
+				report.Address = m[3]
+			}
+			continue
+		}
+		if m := signalAccessRe.FindStringSubmatch(trimmed); m != nil && len(report.AccessType) == 0 {
+			report.AccessType = m[1]
+			continue
+		}
+		if m := summaryRe.FindStringSubmatch(trimmed); m != nil {
+			report.Summary = trimmed
+			break
+		}
+
+		if frame, ok := parseSanitizerFrame(trimmed); ok {
+			if len(report.Frames) > 0 && !inStack {
+				continue
+			}
+			inStack = true
+			report.Frames = append(report.Frames, frame)
+			continue
+		}
+		if inStack && len(trimmed) == 0 {
+			inStack = false
+		}
+	}
+	return report
+}
+
+func parseSanitizerFrame(line string) (output.StackFrame, bool) {
+	m := frameRe.FindStringSubmatch(line)
+	if m == nil {
+		return output.StackFrame{}, false
+	}
+
+	index, _ := strconv.Atoi(m[1])
+	frame := output.StackFrame{
+		Index:   index,
+		Address: m[2],
+	}
+
+	rest := strings.TrimSpace(m[3])
+	if mo := moduleOffsetRe.FindStringSubmatch(rest); mo != nil {
+		frame.Module = mo[1]
+		frame.Offset = mo[2]
+		rest = strings.TrimSpace(rest[:len(rest)-len(mo[0])])
+	}
+
+	// Function names can contain spaces (e.g. "operator new(unsigned long)"), so the location is the last field
+	if idx := strings.LastIndex(rest, " "); idx >= 0 {
+		if fl := fileLineRe.FindStringSubmatch(rest[idx+1:]); fl != nil && strings.ContainsAny(fl[1], "/\\.") {
+			frame.File = fl[1]
+			frame.Line, _ = strconv.Atoi(fl[2])
+			rest = strings.TrimSpace(rest[:idx])
+		}
+	}
+	frame.Function = rest
+	return frame, true
+}
+
+// parseUBSanReport handles the "runtime error" lines of UndefinedBehaviorSanitizer
+func parseUBSanReport(lines []string) *Report {
+	for i, line := range lines {
+		m := ubsanRe.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+
+		lineNo, _ := strconv.Atoi(m[2])
+		report := &Report{
+			Tool:    UndefinedSanitizer,
+			BugType: ubsanBugType(m[3]),
+			Summary: strings.TrimSpace(line),
+		}
+
+		// Stack is only printed with UBSAN_OPTIONS=print_stacktrace=1
+		for _, frameLine := range lines[i+1:] {
+			frame, ok := parseSanitizerFrame(strings.TrimSpace(frameLine))
+			if !ok {
+				if len(report.Frames) > 0 {
+					break
+				}
+				continue
+			}
+			report.Frames = append(report.Frames, frame)
+		}
+		if len(report.Frames) == 0 {
+			report.Frames = []output.StackFrame{{Index: 0, File: m[1], Line: lineNo}}
+		}
+		return report
+	}
+	return nil
+}
+
+func ubsanBugType(message string) string {
+	// The details after the colon contain the values, which differ between the crashes
+	if idx := strings.Index(message, ":"); idx > 0 {
+		message = message[:idx]
+	}
+	message = numberRe.ReplaceAllString(message, "N")
+	message = strings.TrimSpace(message)
+	return strings.ReplaceAll(message, " ", "-")
+}
@@ -0,0 +1,99 @@
+package triage
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/lukjok/gipcfuzz/output"
+)
+
+// Number of the top frames which are used for the crash signature. Deeper frames
+// mostly differ by the call path to the same bug.
+const SignatureFrameCount = 5
+
+var (
+	// Faulting module name: server.exe, version: 0.0.0.0, time stamp: 0x6252ff03
+	eventModuleRe = regexp.MustCompile(`Faulting module name: ([^,\r\n]+)`)
+	// Fault offset: 0x00000000000269e2
+	eventOffsetRe = regexp.MustCompile(`Fault offset: (0x[0-9a-fA-F]+)`)
+	// Template arguments and anonymous namespaces can contain addresses
+	addressRe = regexp.MustCompile(`0x[0-9a-fA-F]+`)
+)
+
+// Signature returns the crash bucket hash computed from the top normalized frames of the best available stack.
+// The sanitizer or core dump stack is preferred, then the faulting module and offset from the event log.
+// If there is no stack, the method path and the error code are used.
+func Signature(crash *output.CrashOutput) string {
+	frames := normalizeFrames(crash.StackTrace)
+	if len(frames) == 0 {
+		frames = eventLogFrames(crash.ExecutableEvents)
+	}
+	if len(frames) == 0 {
+		frames = []string{crash.MethodPath, crash.ErrorCode}
+	}
+
+	key := append([]string{crash.BugType}, frames...)
+	sum := sha1.Sum([]byte(strings.Join(key, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// HangSignature returns the hang bucket hash for the hangs without a stack
+func HangSignature(methodPath string, errorCode string) string {
+	sum := sha1.Sum([]byte(methodPath + "\n" + errorCode))
+	return hex.EncodeToString(sum[:8])
+}
+
+// FindingSignature returns the bucket hash of the finding which has no stack, e.g. a leak or a violated invariant
+func FindingSignature(kind, methodPath string, key ...string) string {
+	sum := sha1.Sum([]byte(strings.Join(append([]string{kind, methodPath}, key...), "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+func normalizeFrames(frames []output.StackFrame) []string {
+	normalized := make([]string, 0, SignatureFrameCount)
+	for _, frame := range frames {
+		// Runtime frames on the top are the same for all crashes of the same type
+		if len(normalized) == 0 && isRuntimeFrame(frame) {
+			continue
+		}
+		if nf := normalizeFrame(frame); len(nf) > 0 {
+			normalized = append(normalized, nf)
+		}
+		if len(normalized) == SignatureFrameCount {
+			break
+		}
+	}
+	return normalized
+}
+
+// normalizeFrame keeps only the parts which do not change between the runs. Line numbers
+// are dropped too, so different lines of the same function end up in the same bucket.
+func normalizeFrame(frame output.StackFrame) string {
+	if len(frame.Function) > 0 {
+		return addressRe.ReplaceAllString(frame.Function, "")
+	}
+	if len(frame.File) > 0 {
+		// Frames without the function name are only told apart by the file
+		return filepath.Base(frame.File)
+	}
+	if len(frame.Module) > 0 {
+		// Module offsets are stable unlike the addresses, which change with ASLR
+		return fmt.Sprintf("%s+%s", filepath.Base(frame.Module), frame.Offset)
+	}
+	return ""
+}
+
+func eventLogFrames(events []string) []string {
+	for _, event := range events {
+		module := eventModuleRe.FindStringSubmatch(event)
+		offset := eventOffsetRe.FindStringSubmatch(event)
+		if module != nil && offset != nil {
+			return []string{fmt.Sprintf("%s+%s", strings.TrimSpace(module[1]), offset[1])}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package triage
+
+import (
+	"strings"
+
+	"github.com/lukjok/gipcfuzz/output"
+)
+
+// Tools which produce the reports
+const (
+	AddressSanitizer   = "AddressSanitizer"
+	UndefinedSanitizer = "UndefinedBehaviorSanitizer"
+	MemorySanitizer    = "MemorySanitizer"
+	ThreadSanitizer    = "ThreadSanitizer"
+	LeakSanitizer      = "LeakSanitizer"
+	GoRuntime          = "Go"
+	RustRuntime        = "Rust"
+)
+
+// Report is the structured information extracted from the sanitizer or runtime panic report
+type Report struct {
+	Tool          string
+	BugType       string
+	AccessType    string
+	AccessSize    int
+	Address       string
+	Summary       string
+	Frames        []output.StackFrame
+	FaultingFrame *output.StackFrame
+}
+
+type reportParser func(lines []string) *Report
+
+// Order matters, since the sanitizers can print the runtime errors of the other ones
+var parsers = []reportParser{
+	parseSanitizerReport,
+	parseUBSanReport,
+	parseGoPanic,
+	parseRustPanic,
+}
+
+// ParseReport looks for the first known report in the process output. Nil is returned
+// if the output does not contain any report.
+func ParseReport(text string) *Report {
+	if len(text) == 0 {
+		return nil
+	}
+
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for _, parser := range parsers {
+		if report := parser(lines); report != nil {
+			report.FaultingFrame = findFaultingFrame(report.Frames)
+			return report
+		}
+	}
+	return nil
+}
+
+// Apply fills the crash output with the report fields
+func (r *Report) Apply(crash *output.CrashOutput) {
+	crash.ReportTool = r.Tool
+	crash.BugType = r.BugType
+	crash.AccessType = r.AccessType
+	crash.AccessSize = r.AccessSize
+	crash.FaultAddress = r.Address
+	crash.StackTrace = r.Frames
+	crash.FaultingFrame = r.FaultingFrame
+	if len(r.Summary) > 0 {
+		crash.ErrorDetails = r.Summary
+	}
+}
+
+// Frames of the sanitizer runtimes and the language runtimes are not the cause of the crash
+var runtimeFramePrefixes = []string{
+	"__asan", "__interceptor_", "__sanitizer", "__tsan", "__msan", "__ubsan", "__lsan", "___interceptor_",
+	"runtime.", "rust_begin_unwind", "__rust", "std::", "core::", "alloc::", "<alloc::", "<core::", "<std::",
+	"__GI_", "__libc_", "__assert_fail", "__pthread_kill",
+}
+
+var runtimeFunctions = map[string]bool{"abort": true, "raise": true, "gsignal": true, "panic": true}
+
+var runtimeModules = []string{"libclang_rt", "libasan", "libtsan", "libmsan", "libubsan", "libc.so", "libc-"}
+
+func isRuntimeFrame(frame output.StackFrame) bool {
+	if runtimeFunctions[frame.Function] {
+		return true
+	}
+	for _, prefix := range runtimeFramePrefixes {
+		if strings.HasPrefix(frame.Function, prefix) {
+			return true
+		}
+	}
+	for _, module := range runtimeModules {
+		if strings.Contains(frame.Module, module) {
+			return true
+		}
+	}
+	return false
+}
+
+func findFaultingFrame(frames []output.StackFrame) *output.StackFrame {
+	for i := 0; i < len(frames); i++ {
+		if !isRuntimeFrame(frames[i]) {
+			frame := frames[i]
+			return &frame
+		}
+	}
+	if len(frames) > 0 {
+		frame := frames[0]
+		return &frame
+	}
+	return nil
+}
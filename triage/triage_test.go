@@ -0,0 +1,198 @@
+package triage
+
+import (
+	"testing"
+
+	"github.com/lukjok/gipcfuzz/output"
+)
+
+func TestParseReport(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		tool       string
+		bugType    string
+		accessType string
+		accessSize int
+		address    string
+		frames     []output.StackFrame
+	}{
+		{
+			name: "asan",
+			text: `=================================================================
+==1234==ERROR: AddressSanitizer: heap-buffer-overflow on address 0x602000000011 at pc 0x4f5a3c bp 0x7ffd sp 0x7ffd
+READ of size 4 at 0x602000000011 thread T0
+    #0 0x4f5a3c in test::TestServiceImpl::MethodOneBad(grpc::ServerContext*, test::Request const*, test::Reply*) /src/server.cc:42:7
+    #1 0x7f0000 in grpc::internal::RpcMethodHandler::RunHandler (/lib/libgrpc++.so+0x1234)
+
+0x602000000011 is located 0 bytes to the right of 1-byte region
+allocated by thread T0 here:
+    #0 0x4a1b2c in operator new(unsigned long) /src/asan_new_delete.cpp:95:3
+    #1 0x4f5a00 in test::TestServiceImpl::MethodOneBad /src/server.cc:40:3
+
+SUMMARY: AddressSanitizer: heap-buffer-overflow /src/server.cc:42:7 in MethodOneBad`,
+			tool:       AddressSanitizer,
+			bugType:    "heap-buffer-overflow",
+			accessType: "READ",
+			accessSize: 4,
+			address:    "0x602000000011",
+			frames: []output.StackFrame{
+				{Index: 0, Address: "0x4f5a3c", Function: "test::TestServiceImpl::MethodOneBad(grpc::ServerContext*, test::Request const*, test::Reply*)", File: "/src/server.cc", Line: 42},
+				{Index: 1, Address: "0x7f0000", Function: "grpc::internal::RpcMethodHandler::RunHandler", Module: "/lib/libgrpc++.so", Offset: "0x1234"},
+			},
+		},
+		{
+			name: "asan segv",
+			text: `==77==ERROR: AddressSanitizer: SEGV on unknown address 0x000000000000 (pc 0x55d0 bp 0x7ffc sp 0x7ffc T0)
+==77==The signal is caused by a WRITE memory access.
+    #0 0x55d0 in handler /src/server.c:10:5`,
+			tool:       AddressSanitizer,
+			bugType:    "SEGV",
+			accessType: "WRITE",
+			address:    "0x000000000000",
+			frames: []output.StackFrame{
+				{Index: 0, Address: "0x55d0", Function: "handler", File: "/src/server.c", Line: 10},
+			},
+		},
+		{
+			name: "tsan",
+			text: `==================
+WARNING: ThreadSanitizer: data race (pid=4242)
+  Write of size 8 at 0x7b0400000000 by thread T1:
+    #0 worker /src/server.cc:20:5 (server+0x4a)
+    #1 start /src/server.cc:30:3 (server+0x5b)
+
+  Previous read of size 8 at 0x7b0400000000 by main thread:
+    #0 reader /src/server.cc:25:9 (server+0x6c)`,
+			tool:       ThreadSanitizer,
+			bugType:    "data-race",
+			accessType: "WRITE",
+			accessSize: 8,
+			address:    "0x7b0400000000",
+			frames: []output.StackFrame{
+				{Index: 0, Function: "worker", File: "/src/server.cc", Line: 20, Module: "server", Offset: "0x4a"},
+				{Index: 1, Function: "start", File: "/src/server.cc", Line: 30, Module: "server", Offset: "0x5b"},
+			},
+		},
+		{
+			name:    "ubsan",
+			text:    `/src/server.cc:12:5: runtime error: signed integer overflow: 2147483647 + 1 cannot be represented in type 'int'`,
+			tool:    UndefinedSanitizer,
+			bugType: "signed-integer-overflow",
+			frames: []output.StackFrame{
+				{Index: 0, File: "/src/server.cc", Line: 12},
+			},
+		},
+		{
+			name: "go panic",
+			text: `panic: runtime error: index out of range [5] with length 3
+
+goroutine 7 [running]:
+main.(*testServiceServer).MethodOneBad(0xc000010000, {0x8a1b20, 0xc000020000}, 0xc000030000)
+	/src/server/main.go:42 +0x1d
+google.golang.org/grpc.(*Server).processUnaryRPC(0xc000040000)
+	/go/pkg/mod/google.golang.org/grpc/server.go:1283 +0xccf
+
+goroutine 1 [IO wait]:
+main.main()
+	/src/server/main.go:80 +0x2a`,
+			tool:    GoRuntime,
+			bugType: "index-out-of-range",
+			frames: []output.StackFrame{
+				{Index: 0, Function: "main.(*testServiceServer).MethodOneBad", File: "/src/server/main.go", Line: 42, Offset: "0x1d"},
+				{Index: 1, Function: "google.golang.org/grpc.(*Server).processUnaryRPC", File: "/go/pkg/mod/google.golang.org/grpc/server.go", Line: 1283, Offset: "0xccf"},
+			},
+		},
+		{
+			name: "go nil pointer",
+			text: `panic: runtime error: invalid memory address or nil pointer dereference
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x4553a9]
+
+goroutine 5 [running]:
+main.handler(...)
+	/src/main.go:12`,
+			tool:    GoRuntime,
+			bugType: "nil-pointer-dereference",
+			address: "0x0",
+			frames: []output.StackFrame{
+				{Index: 0, Function: "main.handler", File: "/src/main.go", Line: 12},
+			},
+		},
+		{
+			name: "go fatal error",
+			text: `fatal error: concurrent map writes
+
+goroutine 9 [running]:
+main.store(0xc000010000)
+	/src/main.go:30 +0x45`,
+			tool:    GoRuntime,
+			bugType: "concurrent-map-writes",
+			frames: []output.StackFrame{
+				{Index: 0, Function: "main.store", File: "/src/main.go", Line: 30, Offset: "0x45"},
+			},
+		},
+		{
+			name:    "rust old panic",
+			text:    `thread 'main' panicked at 'index out of bounds: the len is 3 but the index is 5', src/main.rs:4:5`,
+			tool:    RustRuntime,
+			bugType: "index-out-of-bounds",
+			frames: []output.StackFrame{
+				{Index: 0, File: "src/main.rs", Line: 4},
+			},
+		},
+		{
+			name: "rust panic with backtrace",
+			text: `thread 'tokio-runtime-worker' panicked at src/service.rs:17:9:
+called ` + "`Option::unwrap()` on a `None`" + ` value
+stack backtrace:
+   0: rust_begin_unwind
+             at /rustc/library/std/src/panicking.rs:645:5
+   1: server::service::handler::h1a2b3c4d5e6f7a8b
+             at ./src/service.rs:17:9
+note: Some details are omitted`,
+			tool:    RustRuntime,
+			bugType: "unwrap-on-none",
+			frames: []output.StackFrame{
+				{Index: 0, Function: "rust_begin_unwind", File: "/rustc/library/std/src/panicking.rs", Line: 645},
+				{Index: 1, Function: "server::service::handler", File: "./src/service.rs", Line: 17},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		report := ParseReport(test.text)
+		if report == nil {
+			t.Errorf("%s: no report", test.name)
+			continue
+		}
+		if report.Tool != test.tool || report.BugType != test.bugType {
+			t.Errorf("%s: got %s %s, want %s %s", test.name, report.Tool, report.BugType, test.tool, test.bugType)
+		}
+		if report.AccessType != test.accessType || report.AccessSize != test.accessSize || report.Address != test.address {
+			t.Errorf("%s: got access %s %d at %s, want %s %d at %s", test.name,
+				report.AccessType, report.AccessSize, report.Address, test.accessType, test.accessSize, test.address)
+		}
+		if len(report.Frames) != len(test.frames) {
+			t.Errorf("%s: got %d frames, want %d: %+v", test.name, len(report.Frames), len(test.frames), report.Frames)
+			continue
+		}
+		for i, frame := range report.Frames {
+			if frame != test.frames[i] {
+				t.Errorf("%s: frame %d is %+v, want %+v", test.name, i, frame, test.frames[i])
+			}
+		}
+	}
+}
+
+func TestParseReportNone(t *testing.T) {
+	texts := []string{
+		"",
+		"Server listening on 0.0.0.0:50051",
+		"ERROR: failed to open the config file",
+	}
+	for _, text := range texts {
+		if report := ParseReport(text); report != nil {
+			t.Errorf("ParseReport(%q) = %+v, want nil", text, report)
+		}
+	}
+}
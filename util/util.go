@@ -1,6 +1,8 @@
 package util
 
 import (
+	"context"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net"
@@ -11,6 +13,8 @@ import (
 	"github.com/lukjok/gipcfuzz/config"
 	"github.com/lukjok/gipcfuzz/models"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func GetFileNamesInDirectory(fileDir string, ignoreDirs []string) []string {
@@ -116,6 +120,18 @@ func ConvertError(err error) models.GIPCFuzzError {
 	}
 }
 
+// IsTimeoutError tells if the call has run out of time, either by the deadline of the gRPC call or the socket
+func IsTimeoutError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if status.Code(err) == codes.DeadlineExceeded || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
 func GetMethodHandler(method string, handlers []config.Handler) *config.Handler {
 	for i := 0; i < len(handlers); i++ {
 		if handlers[i].Method == method {
@@ -125,6 +141,31 @@ func GetMethodHandler(method string, handlers []config.Handler) *config.Handler
 	return nil
 }
 
+// GetTargetEndpoint returns the configured target or falls back to the host and port settings
+func GetTargetEndpoint(settings config.Configuration) string {
+	if len(settings.Target) > 0 {
+		return settings.Target
+	}
+	return fmt.Sprintf("%s:%d", settings.Host, settings.Port)
+}
+
+// GetTargetPort returns the TCP port of the configured target or empty string for the socket targets
+func GetTargetPort(settings config.Configuration) string {
+	_, port, err := net.SplitHostPort(GetTargetEndpoint(settings))
+	if err != nil {
+		return ""
+	}
+	return port
+}
+
+// GetCorpusPath returns the configured corpus directory or the default one in the output directory
+func GetCorpusPath(settings config.Configuration) string {
+	if len(settings.CorpusPath) > 0 {
+		return settings.CorpusPath
+	}
+	return filepath.Join(settings.OutputPath, "Corpus")
+}
+
 func GetMapKeyByValue(data map[string]int, val int) string {
 	for k, v := range data {
 		if v == val {
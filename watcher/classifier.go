@@ -0,0 +1,83 @@
+package watcher
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// Shells report the processes killed by a signal with this offset added to the signal number
+const shellSignalExitOffset = 128
+
+// CrashClass is the classification of the process exit
+type CrashClass struct {
+	IsCrash  bool
+	Code     string
+	Category string
+	Cause    string
+}
+
+var signalClasses = map[syscall.Signal]CrashClass{
+	syscall.SIGSEGV: {IsCrash: true, Code: "SIGSEGV", Category: segmentationFaultCategory, Cause: "Invalid memory access (null-pointer dereference, use-after-free or out-of-bounds access)"},
+	syscall.SIGBUS:  {IsCrash: true, Code: "SIGBUS", Category: busErrorCategory, Cause: "Misaligned access or access to the unmapped part of the mapped file"},
+	syscall.SIGABRT: {IsCrash: true, Code: "SIGABRT", Category: abortCategory, Cause: "Process aborted itself (failed assertion, heap corruption detected by the allocator or sanitizer report)"},
+	syscall.SIGFPE:  {IsCrash: true, Code: "SIGFPE", Category: arithmeticErrorCategory, Cause: "Erroneous arithmetic operation (e.g. integer division by zero or overflow)"},
+	syscall.SIGILL:  {IsCrash: true, Code: "SIGILL", Category: illegalInstructionCategory, Cause: "Illegal instruction (corrupted code pointer or compiler inserted trap)"},
+	syscall.SIGTRAP: {IsCrash: true, Code: "SIGTRAP", Category: trapCategory, Cause: "Trace or breakpoint trap (debug break or compiler inserted trap)"},
+}
+
+// ClassifyExitStatus maps the terminating signal, exit code and the core dump flag of the POSIX
+// process to the crash category. Clean exits and exits caused by the fuzzer killing the process are not crashes.
+func ClassifyExitStatus(status *ExitStatus) CrashClass {
+	if status == nil {
+		return CrashClass{Code: "", Category: unknownError}
+	}
+
+	if status.Signaled {
+		return classifySignal(syscall.Signal(status.Signal), status.CoreDumped)
+	}
+
+	switch {
+	case status.ExitCode == 0:
+		return CrashClass{IsCrash: false, Code: "EXIT_0", Category: cleanExitCategory}
+	case status.ExitCode > shellSignalExitOffset && status.ExitCode < shellSignalExitOffset+64:
+		// Target is started through a shell script which reports the signal of its child as an exit code
+		return classifySignal(syscall.Signal(status.ExitCode-shellSignalExitOffset), status.CoreDumped)
+	default:
+		return CrashClass{
+			IsCrash:  true,
+			Code:     fmt.Sprintf("EXIT_%d", status.ExitCode),
+			Category: abnormalExitCategory,
+			Cause:    fmt.Sprintf("Process exited with the code %d", status.ExitCode),
+		}
+	}
+}
+
+// MayHaveDumpedCore tells if the process could have left a core file. Only the signals dump the cores,
+// and the signal of the child reported by a shell does not tell if its core was dumped.
+func MayHaveDumpedCore(status *ExitStatus) bool {
+	if status == nil {
+		return false
+	}
+	if status.Signaled {
+		return status.CoreDumped
+	}
+	return status.ExitCode > shellSignalExitOffset && status.ExitCode < shellSignalExitOffset+64
+}
+
+func classifySignal(signal syscall.Signal, coreDumped bool) CrashClass {
+	class, ok := signalClasses[signal]
+	if !ok {
+		// SIGKILL and SIGTERM are sent by the fuzzer itself or by the OOM killer, others are unexpected
+		isCrash := signal != syscall.SIGKILL && signal != syscall.SIGTERM && signal != syscall.SIGINT
+		class = CrashClass{
+			IsCrash:  isCrash || coreDumped,
+			Code:     fmt.Sprintf("SIG%d", int(signal)),
+			Category: killedBySignalCategory,
+			Cause:    fmt.Sprintf("Process was terminated by the signal %d (%s)", int(signal), signal.String()),
+		}
+	}
+	if coreDumped {
+		class.Cause += ", core dumped"
+	}
+	return class
+}
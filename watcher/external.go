@@ -0,0 +1,181 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lukjok/gipcfuzz/config"
+	"github.com/lukjok/gipcfuzz/models"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultRestartTimeout = 30 * time.Second
+	externalPollInterval  = 200 * time.Millisecond
+)
+
+// externalProcess is the target started outside the fuzzer. Its exit status cannot be observed,
+// so only the disappearance of the PID tells that it has ended.
+var (
+	externalLock sync.Mutex
+	externalPid  int
+	// Name of the attached process, so it can be found again when it was attached by the PID
+	externalName string
+)
+
+func externalSettings(ctx context.Context) (config.ExternalConfig, bool) {
+	ctxData := ctx.Value("data").(models.ContextData)
+	return ctxData.Settings.External, ctxData.Settings.External.Enabled
+}
+
+func isExternalRunning(ctx context.Context) bool {
+	return getExternalPid(ctx) != 0
+}
+
+// getExternalPid returns the PID of the attached process or 0 once it has ended. The process restarted by
+// its supervisor is not looked up here, so the end of the attached one is seen as a crash. Only
+// startExternalProcess attaches to the new process.
+func getExternalPid(ctx context.Context) int {
+	externalLock.Lock()
+	pid, name := externalPid, externalName
+	externalLock.Unlock()
+
+	if pid == 0 {
+		return 0
+	}
+	proc, err := FindProcess(pid)
+	if err != nil || proc == nil {
+		return 0
+	}
+	// PID may already be reused by another process
+	if len(name) > 0 && proc.Executable() != name {
+		return 0
+	}
+	return pid
+}
+
+func killExternalProcess(ctx context.Context) {
+	pid := getExternalPid(ctx)
+	if pid == 0 {
+		return
+	}
+	if proc, err := os.FindProcess(pid); err == nil {
+		proc.Kill()
+	}
+	for deadline := time.Now().Add(defaultRestartTimeout); time.Now().Before(deadline) && isPidRunning(pid); {
+		time.Sleep(externalPollInterval)
+	}
+}
+
+// startExternalProcess attaches to the running target or restarts it with the user command. "EXIT" is sent
+// as the output, same as the Windows backend does, since the exit status of the previous process is not known.
+func startExternalProcess(ctx context.Context, status chan *StartProcessResponse) {
+	external, _ := externalSettings(ctx)
+	restartTimeout := defaultRestartTimeout
+	if external.RestartTimeout > 0 {
+		restartTimeout = time.Duration(external.RestartTimeout) * time.Millisecond
+	}
+
+	pid, err := findExternalPid(ctx)
+	if err != nil {
+		if len(external.RestartCommand) == 0 {
+			sendStartResponse(ctx, status, NewStartProcessResponse(
+				errors.Errorf("External target is not running and no restart command is set: %s", err), ""))
+			return
+		}
+		if err := runRestartCommand(ctx, external.RestartCommand, restartTimeout); err != nil {
+			sendStartResponse(ctx, status, NewStartProcessResponse(err, ""))
+			return
+		}
+		if pid, err = waitForExternalPid(ctx, restartTimeout); err != nil {
+			sendStartResponse(ctx, status, NewStartProcessResponse(err, ""))
+			return
+		}
+	}
+
+	response := NewStartProcessResponse(nil, "EXIT")
+	response.Pid = pid
+	sendStartResponse(ctx, status, response)
+}
+
+func runRestartCommand(ctx context.Context, command []string, timeout time.Duration) error {
+	cmdCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(cmdCtx, command[0], command[1:]...).CombinedOutput()
+	if err != nil {
+		return errors.Errorf("Restart command %s has failed: %s %s", strings.Join(command, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func waitForExternalPid(ctx context.Context, timeout time.Duration) (int, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		pid, err := findExternalPid(ctx)
+		if err == nil {
+			return pid, nil
+		}
+		if time.Now().After(deadline) {
+			return 0, errors.Errorf("External target was not found after the restart: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(externalPollInterval):
+		}
+	}
+}
+
+// findExternalPid looks up the target and remembers it. The configured PID is only used for the first attach,
+// since the restarted process gets a new one.
+func findExternalPid(ctx context.Context) (int, error) {
+	ctxData := ctx.Value("data").(models.ContextData)
+	external := ctxData.Settings.External
+
+	externalLock.Lock()
+	defer externalLock.Unlock()
+
+	pid := 0
+	switch {
+	case externalPid == 0 && external.Pid != 0:
+		if !isPidRunning(external.Pid) {
+			return 0, errors.Errorf("Process %d is not running", external.Pid)
+		}
+		pid = external.Pid
+	case len(external.Socket) > 0:
+		owner, err := findSocketOwner(external.Socket)
+		if err != nil {
+			return 0, err
+		}
+		pid = owner
+	default:
+		name := external.ProcessName
+		if len(name) == 0 {
+			name = externalName
+		}
+		if len(name) == 0 {
+			name = filepath.Base(ctxData.Settings.PathToExecutable)
+		}
+		proc, err := getProcessByName(name)
+		if err != nil {
+			return 0, err
+		}
+		if proc == nil || proc.Pid == 0 {
+			return 0, errors.Errorf("Process %s was not found", name)
+		}
+		pid = proc.Pid
+	}
+
+	externalPid = pid
+	if proc, err := FindProcess(pid); err == nil && proc != nil {
+		externalName = proc.Executable()
+	}
+	return pid, nil
+}
@@ -0,0 +1,33 @@
+package watcher
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	"github.com/lukjok/gipcfuzz/config"
+)
+
+// launchEnvironment returns the environment of the fuzzer with the configured variables added.
+// Variables are sorted, so the target gets the same environment on every start.
+func launchEnvironment(launch config.LaunchConfig) []string {
+	if len(launch.Environment) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(launch.Environment))
+	for name := range launch.Environment {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	env := os.Environ()
+	for _, name := range names {
+		env = append(env, name+"="+launch.Environment[name])
+	}
+	return env
+}
+
+func wallClockLimit(launch config.LaunchConfig) time.Duration {
+	return time.Duration(launch.WallClockLimit) * time.Millisecond
+}
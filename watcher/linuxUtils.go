@@ -0,0 +1,107 @@
+//go:build linux
+// +build linux
+
+package watcher
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LinuxProcess is an implementation of Process for Linux.
+type LinuxProcess struct {
+	pid  int
+	ppid int
+	exe  string
+}
+
+func (p *LinuxProcess) Pid() int {
+	return p.pid
+}
+
+func (p *LinuxProcess) PPid() int {
+	return p.ppid
+}
+
+func (p *LinuxProcess) Executable() string {
+	return p.exe
+}
+
+func newLinuxProcess(pid int) (*LinuxProcess, error) {
+	statPath := fmt.Sprintf("/proc/%d/stat", pid)
+	data, err := ioutil.ReadFile(statPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Name is in the parentheses and can contain spaces, so the fields are parsed after the last one
+	stat := string(data)
+	start := strings.IndexRune(stat, '(')
+	end := strings.LastIndex(stat, ")")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("Unexpected format of %s", statPath)
+	}
+
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("Unexpected format of %s", statPath)
+	}
+	// Zombies are already dead, they are only waiting to be reaped
+	if fields[0] == "Z" {
+		return nil, nil
+	}
+	ppid, _ := strconv.Atoi(fields[1])
+
+	// The name in the stat file is truncated to 15 characters, so the executable link is preferred
+	exe := stat[start+1 : end]
+	if exePath, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid)); err == nil {
+		exe = filepath.Base(strings.TrimSuffix(exePath, " (deleted)"))
+	}
+
+	return &LinuxProcess{
+		pid:  pid,
+		ppid: ppid,
+		exe:  exe,
+	}, nil
+}
+
+func findProcess(pid int) (Process, error) {
+	proc, err := newLinuxProcess(pid)
+	if os.IsNotExist(err) || proc == nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return proc, nil
+}
+
+func processes() ([]Process, error) {
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Process, 0, 50)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		// Processes can exit while the list is being read
+		proc, err := newLinuxProcess(pid)
+		if err != nil || proc == nil {
+			continue
+		}
+		results = append(results, proc)
+	}
+
+	return results, nil
+}
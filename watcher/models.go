@@ -1,9 +1,28 @@
 package watcher
 
+import (
+	"time"
+
+	"github.com/lukjok/gipcfuzz/output"
+)
+
 const bufferOverflowError string = "Buffer overflow"
 const memoryCorruptionError string = "Null-pointer dereference"
 const unknownError string = "Unknown error"
 
+// Crash categories of the POSIX processes
+const (
+	segmentationFaultCategory  string = "Segmentation fault"
+	busErrorCategory           string = "Bus error"
+	abortCategory              string = "Abort"
+	arithmeticErrorCategory    string = "Arithmetic error"
+	illegalInstructionCategory string = "Illegal instruction"
+	trapCategory               string = "Trap"
+	killedBySignalCategory     string = "Killed by signal"
+	abnormalExitCategory       string = "Abnormal exit"
+	cleanExitCategory          string = "Clean exit"
+)
+
 var bufferOverflowCodes = [...]string{"0xc00000fd", "0xc0000409", "0xc0000374"}
 var memoryCorruptionCodes = [...]string{"0xc0000005"}
 
@@ -42,6 +61,24 @@ func FindProcess(pid int) (Process, error) {
 type StartProcessResponse struct {
 	Error  error
 	Output string
+	// Pid of the started process, if the platform backend keeps it
+	Pid int
+	// LastExit describes how the previously started process has ended, nil if it is not known
+	LastExit *ExitStatus
+}
+
+// ExitStatus is collected when the supervised process is waited for
+type ExitStatus struct {
+	Pid        int
+	ExitCode   int
+	Signal     int
+	Signaled   bool
+	CoreDumped bool
+	Output     string
+	// Context is captured only when the process is supervised with ptrace
+	Context *output.CrashContext
+	// TimedOut is set when the process was killed after reaching the wall-clock limit
+	TimedOut bool
 }
 
 func NewStartProcessResponse(e error, output string) *StartProcessResponse {
@@ -50,3 +87,12 @@ func NewStartProcessResponse(e error, output string) *StartProcessResponse {
 		Output: output,
 	}
 }
+
+// ResourceUsage is a point-in-time sample of the resources held by the process
+type ResourceUsage struct {
+	// Resident set size in bytes
+	RSS       uint64
+	CPUTime   time.Duration
+	Threads   int
+	OpenFiles int
+}
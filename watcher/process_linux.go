@@ -0,0 +1,277 @@
+//go:build linux
+// +build linux
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/lukjok/gipcfuzz/config"
+	"github.com/lukjok/gipcfuzz/models"
+	"github.com/lukjok/gipcfuzz/output"
+	"github.com/pkg/errors"
+)
+
+// supervisedProcess is the target started by the fuzzer. Unlike on Windows, it is the direct
+// child of the fuzzer, so the exit status is observed instead of looking for the process by name.
+type supervisedProcess struct {
+	cmd    *exec.Cmd
+	output *RingBuffer
+	exited chan struct{}
+	limits config.ResourceLimits
+	// Set when the process is killed after reaching the wall-clock limit
+	timedOut int32
+	// Requests of the hang context, only the traced process has them
+	hangRequests chan hangRequest
+}
+
+// hangRequest asks the tracer for the context of the thread, which is stopped for it with SIGSTOP
+type hangRequest struct {
+	tid     int
+	expires time.Time
+	reply   chan *output.CrashContext
+}
+
+var (
+	supervisorLock sync.Mutex
+	supervised     *supervisedProcess
+	lastExit       *ExitStatus
+)
+
+func IsProcessRunning(ctx context.Context) bool {
+	if _, ok := externalSettings(ctx); ok {
+		return isExternalRunning(ctx)
+	}
+	supervisorLock.Lock()
+	proc := supervised
+	supervisorLock.Unlock()
+
+	if proc != nil {
+		select {
+		case <-proc.exited:
+			return false
+		default:
+			return true
+		}
+	}
+
+	// Target was not started by the fuzzer, so fall back to the name lookup
+	ctxData := ctx.Value("data").(models.ContextData)
+	_, err := getProcessByName(filepath.Base(ctxData.Settings.PathToExecutable))
+	return err == nil
+}
+
+func KillProcess(ctx context.Context) {
+	if _, ok := externalSettings(ctx); ok {
+		killExternalProcess(ctx)
+		return
+	}
+	supervisorLock.Lock()
+	proc := supervised
+	supervisorLock.Unlock()
+
+	if proc != nil {
+		proc.cmd.Process.Kill()
+		<-proc.exited
+		return
+	}
+
+	ctxData := ctx.Value("data").(models.ContextData)
+	if osProc, err := getProcessByName(filepath.Base(ctxData.Settings.PathToExecutable)); err == nil {
+		osProc.Kill()
+	}
+}
+
+// GetProcessPid returns the PID of the running target or 0 if there is none
+func GetProcessPid(ctx context.Context) int {
+	if _, ok := externalSettings(ctx); ok {
+		return getExternalPid(ctx)
+	}
+	supervisorLock.Lock()
+	proc := supervised
+	supervisorLock.Unlock()
+
+	if proc != nil {
+		select {
+		case <-proc.exited:
+			return 0
+		default:
+			return proc.cmd.Process.Pid
+		}
+	}
+
+	ctxData := ctx.Value("data").(models.ContextData)
+	if osProc, err := getProcessByName(filepath.Base(ctxData.Settings.PathToExecutable)); err == nil {
+		return osProc.Pid
+	}
+	return 0
+}
+
+// CaptureHangContext returns the context of the busy thread of the hanging target. It is only available
+// for the traced target, and nil is returned when no thread is running.
+func CaptureHangContext(ctx context.Context) *output.CrashContext {
+	if _, ok := externalSettings(ctx); ok {
+		return nil
+	}
+	supervisorLock.Lock()
+	proc := supervised
+	supervisorLock.Unlock()
+
+	if proc == nil || proc.hangRequests == nil {
+		return nil
+	}
+	return proc.captureHang()
+}
+
+// GetLastExitStatus returns how the last supervised process has ended or nil if none has exited yet
+func GetLastExitStatus() *ExitStatus {
+	supervisorLock.Lock()
+	defer supervisorLock.Unlock()
+	return lastExit
+}
+
+// StartProcess starts the target directly and reports the exit of the previous one. The output of the
+// previous process is sent as the response output if it has crashed, otherwise "EXIT" is sent, same as
+// the Windows backend does after the process is started.
+func StartProcess(ctx context.Context, status chan *StartProcessResponse) {
+	if _, ok := externalSettings(ctx); ok {
+		startExternalProcess(ctx, status)
+		return
+	}
+	ctxData := ctx.Value("data").(models.ContextData)
+
+	launch := ctxData.Settings.Launch
+
+	cmd := exec.Command(ctxData.Settings.PathToExecutable, ctxData.Settings.ExecutableArguments...)
+	outBuf := NewRingBuffer(defaultOutputBufferSize)
+	cmd.Stdout = outBuf
+	cmd.Stderr = outBuf
+	cmd.Env = launchEnvironment(launch)
+	cmd.Dir = launch.WorkingDirectory
+	if len(launch.StdinFile) > 0 {
+		stdin, err := os.Open(launch.StdinFile)
+		if err != nil {
+			sendStartResponse(ctx, status, NewStartProcessResponse(errors.Errorf("Failed to open the stdin file: %s", err), ""))
+			return
+		}
+		// Child gets its own descriptor, so the file can be closed once the process is started
+		defer stdin.Close()
+		cmd.Stdin = stdin
+	}
+
+	proc := &supervisedProcess{
+		cmd:    cmd,
+		output: outBuf,
+		exited: make(chan struct{}),
+		limits: launch.Limits,
+	}
+
+	if ctxData.Settings.UsePtrace {
+		// Tracing goroutine starts the process itself, since ptrace requests must come from the same thread
+		if err := proc.startTraced(); err != nil {
+			sendStartResponse(ctx, status, NewStartProcessResponse(err, ""))
+			return
+		}
+	} else {
+		// Limits are applied while the process is stopped at the exec, same as for the traced process
+		if err := startLimited(cmd, proc.limits); err != nil {
+			sendStartResponse(ctx, status, NewStartProcessResponse(err, ""))
+			return
+		}
+		go proc.wait()
+	}
+	if limit := wallClockLimit(launch); limit > 0 {
+		go proc.limitWallClock(limit)
+	}
+
+	supervisorLock.Lock()
+	prevExit := lastExit
+	supervised = proc
+	supervisorLock.Unlock()
+
+	response := NewStartProcessResponse(nil, "EXIT")
+	response.Pid = cmd.Process.Pid
+	response.LastExit = prevExit
+	if prevExit != nil && ClassifyExitStatus(prevExit).IsCrash && len(prevExit.Output) > 0 {
+		response.Output = prevExit.Output
+	}
+	sendStartResponse(ctx, status, response)
+
+	select {
+	case <-ctx.Done():
+		cmd.Process.Kill()
+		<-proc.exited
+	case <-proc.exited:
+	}
+}
+
+func (p *supervisedProcess) wait() {
+	// Error only tells that the process did not exit with zero, the details are in the process state
+	p.cmd.Wait()
+
+	if ws, ok := p.cmd.ProcessState.Sys().(syscall.WaitStatus); ok {
+		p.finish(ws, nil)
+		return
+	}
+	p.finish(syscall.WaitStatus(p.cmd.ProcessState.ExitCode()<<8), nil)
+}
+
+// limitWallClock kills the process once it runs longer than the limit
+func (p *supervisedProcess) limitWallClock(limit time.Duration) {
+	timer := time.NewTimer(limit)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		atomic.StoreInt32(&p.timedOut, 1)
+		p.cmd.Process.Kill()
+	case <-p.exited:
+	}
+}
+
+// finish records the exit status of the process and marks it as exited
+func (p *supervisedProcess) finish(ws syscall.WaitStatus, crashContext *output.CrashContext) {
+	exit := &ExitStatus{
+		Pid:        p.cmd.Process.Pid,
+		ExitCode:   ws.ExitStatus(),
+		Signaled:   ws.Signaled(),
+		CoreDumped: ws.CoreDump(),
+		Output:     p.output.String(),
+		Context:    crashContext,
+		TimedOut:   atomic.LoadInt32(&p.timedOut) == 1,
+	}
+	if exit.Signaled {
+		exit.Signal = int(ws.Signal())
+	}
+
+	supervisorLock.Lock()
+	lastExit = exit
+	supervisorLock.Unlock()
+	close(p.exited)
+}
+
+func isPidRunning(pid int) bool {
+	proc, err := findProcess(pid)
+	return err == nil && proc != nil
+}
+
+func getProcessByName(executableName string) (*os.Process, error) {
+	procList, err := processes()
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range procList {
+		if value.Executable() == executableName {
+			return os.FindProcess(value.Pid())
+		}
+	}
+	return nil, fmt.Errorf("Process %s was not found", executableName)
+}
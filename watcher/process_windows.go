@@ -0,0 +1,206 @@
+//go:build windows
+// +build windows
+
+package watcher
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+
+	//"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/lukjok/gipcfuzz/models"
+	"github.com/lukjok/gipcfuzz/output"
+)
+
+// PID of the process started by the fuzzer, so other instances with the same name are not mistaken for it
+var launchedPid int32
+
+func IsProcessRunning(ctx context.Context) bool {
+	if _, ok := externalSettings(ctx); ok {
+		return isExternalRunning(ctx)
+	}
+	if pid := atomic.LoadInt32(&launchedPid); pid != 0 {
+		return isPidRunning(int(pid))
+	}
+
+	ctxData := ctx.Value("data").(models.ContextData)
+	execName := filepath.Base(ctxData.Settings.PathToExecutable)
+
+	_, err := getProcessByName(execName)
+	return err == nil
+}
+
+// GetProcessPid returns the PID of the running target or 0 if there is none
+func GetProcessPid(ctx context.Context) int {
+	if _, ok := externalSettings(ctx); ok {
+		return getExternalPid(ctx)
+	}
+	if pid := atomic.LoadInt32(&launchedPid); pid != 0 {
+		if isPidRunning(int(pid)) {
+			return int(pid)
+		}
+		return 0
+	}
+
+	ctxData := ctx.Value("data").(models.ContextData)
+	execName := filepath.Base(ctxData.Settings.PathToExecutable)
+
+	proc, err := getProcessByName(execName)
+	if err != nil {
+		return 0
+	}
+	return proc.Pid
+}
+
+func KillProcess(ctx context.Context) {
+	if _, ok := externalSettings(ctx); ok {
+		killExternalProcess(ctx)
+		return
+	}
+	if pid := atomic.LoadInt32(&launchedPid); pid != 0 {
+		if proc, err := os.FindProcess(int(pid)); err == nil {
+			proc.Kill()
+		}
+		return
+	}
+
+	ctxData := ctx.Value("data").(models.ContextData)
+	execName := filepath.Base(ctxData.Settings.PathToExecutable)
+
+	proc, _ := getProcessByName(execName)
+	proc.Kill()
+}
+
+// CaptureHangContext returns nil, since the target is not traced on Windows
+func CaptureHangContext(ctx context.Context) *output.CrashContext {
+	return nil
+}
+
+func StartProcess(ctx context.Context, status chan *StartProcessResponse) {
+	if _, ok := externalSettings(ctx); ok {
+		startExternalProcess(ctx, status)
+		return
+	}
+	ctxData := ctx.Value("data").(models.ContextData)
+	//execPath := filepath.Dir(ctxData.Settings.PathToExecutable)
+	// PowerShell prints the PID of the started process, which is used to track its liveness
+	launch := ctxData.Settings.Launch
+	arguments := []string{"(Start-Process", ctxData.Settings.PathToExecutable}
+	arguments = append(arguments, ctxData.Settings.ExecutableArguments...)
+	if len(launch.WorkingDirectory) > 0 {
+		arguments = append(arguments, "-WorkingDirectory", quotePowerShell(launch.WorkingDirectory))
+	}
+	if len(launch.StdinFile) > 0 {
+		arguments = append(arguments, "-RedirectStandardInput", quotePowerShell(launch.StdinFile))
+	}
+	arguments = append(arguments, "-PassThru).Id")
+
+	//log.Printf("Starting process %s", execPath)
+	cmd := exec.Command("C:\\Windows\\System32\\WindowsPowerShell\\v1.0\\powershell.exe", arguments...)
+	// Started process inherits the environment of PowerShell
+	cmd.Env = launchEnvironment(launch)
+	//cmd.Stderr = os.Stderr
+	//cmd.Stdout = os.Stdout
+	stderr, _ := cmd.StderrPipe()
+	stdout, _ := cmd.StdoutPipe()
+	if err := cmd.Start(); err != nil {
+		sendStartResponse(ctx, status, NewStartProcessResponse(err, ""))
+		return
+	}
+
+	atomic.StoreInt32(&launchedPid, 0)
+	if pidOutput, err := ioutil.ReadAll(stdout); err == nil {
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(pidOutput))); err == nil {
+			atomic.StoreInt32(&launchedPid, int32(pid))
+		}
+	}
+	if limit := wallClockLimit(launch); limit > 0 {
+		if pid := atomic.LoadInt32(&launchedPid); pid != 0 {
+			go limitWallClock(ctx, int(pid), limit)
+		}
+	}
+
+	buf := new(strings.Builder)
+	if _, err := io.Copy(buf, stderr); err != nil {
+		sendStartResponse(ctx, status, NewStartProcessResponse(err, ""))
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			//log.Println("Killing process...")
+			cmd.Process.Kill()
+			return
+		default:
+			if cmd.ProcessState != nil && cmd.ProcessState.Exited() {
+				sendStartResponse(ctx, status, NewStartProcessResponse(nil, "EXIT"))
+			} else {
+				sendStartResponse(ctx, status, NewStartProcessResponse(nil, buf.String()))
+			}
+			cmd.Wait()
+		}
+	}
+}
+
+// limitWallClock kills the process once it runs longer than the limit. Resource limits are not supported on Windows.
+func limitWallClock(ctx context.Context, pid int, limit time.Duration) {
+	deadline := time.Now().Add(limit)
+	for isPidRunning(pid) {
+		if time.Now().After(deadline) {
+			if proc, err := os.FindProcess(pid); err == nil {
+				proc.Kill()
+			}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func quotePowerShell(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+func isPidRunning(pid int) bool {
+	procList, err := processes()
+	if err != nil {
+		return false
+	}
+	for _, value := range procList {
+		if value.Pid() == pid {
+			return true
+		}
+	}
+	return false
+}
+
+func getProcessByName(executableName string) (*os.Process, error) {
+	procList, err := processes()
+	if err != nil {
+		return nil, err
+	}
+	var pid int = 0
+	for _, value := range procList {
+		if value.Executable() == executableName {
+			pid = value.Pid()
+			break
+		}
+	}
+
+	proc, err := os.FindProcess(pid)
+	return proc, err
+}
@@ -0,0 +1,377 @@
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+package watcher
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/lukjok/gipcfuzz/memdump"
+	"github.com/lukjok/gipcfuzz/output"
+	"github.com/pkg/errors"
+)
+
+const (
+	ptraceGetSigInfo     = 0x4202
+	ptraceOptExitKill    = 0x100000
+	sigInfoSize          = 128
+	sigInfoCodeOffset    = 8
+	sigInfoAddrOffset    = 16
+	instructionByteCount = 16
+	maxBacktraceFrames   = 64
+	stopPollInterval     = 10 * time.Millisecond
+	hangContextTimeout   = time.Second
+)
+
+// Signals which terminate the process with a crash, the context is captured before they are delivered
+var fatalSignals = map[syscall.Signal]bool{
+	syscall.SIGSEGV: true,
+	syscall.SIGBUS:  true,
+	syscall.SIGILL:  true,
+	syscall.SIGFPE:  true,
+	syscall.SIGABRT: true,
+	syscall.SIGTRAP: true,
+}
+
+// startTraced starts the process under ptrace. Every ptrace request has to come from the thread which
+// has started the process, so the whole tracing is done in the single locked goroutine.
+func (p *supervisedProcess) startTraced() error {
+	p.cmd.SysProcAttr = &syscall.SysProcAttr{Ptrace: true, Setpgid: true}
+	p.hangRequests = make(chan hangRequest, 1)
+
+	started := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		if err := p.cmd.Start(); err != nil {
+			started <- err
+			return
+		}
+
+		// Child stops with SIGTRAP right after the exec
+		pid := p.cmd.Process.Pid
+		var ws syscall.WaitStatus
+		if _, err := syscall.Wait4(pid, &ws, syscall.WALL, nil); err != nil || !ws.Stopped() {
+			p.cmd.Process.Kill()
+			p.cmd.Wait()
+			started <- errors.Errorf("Failed to trace the process %d: %v", pid, err)
+			return
+		}
+		if err := syscall.PtraceSetOptions(pid, syscall.PTRACE_O_TRACECLONE|syscall.PTRACE_O_TRACEEXEC|ptraceOptExitKill); err != nil {
+			p.cmd.Process.Kill()
+			p.cmd.Wait()
+			started <- errors.Errorf("Failed to set the ptrace options: %s", err)
+			return
+		}
+		if err := setResourceLimits(pid, p.limits); err != nil {
+			p.cmd.Process.Kill()
+			p.cmd.Wait()
+			started <- err
+			return
+		}
+		started <- syscall.PtraceCont(pid, 0)
+
+		ws, crashContext := p.trace(pid)
+		// Output is copied by the goroutines of the command, the process itself is already reaped
+		p.cmd.Wait()
+		if !ws.Signaled() && ws.ExitStatus() == 0 {
+			crashContext = nil
+		}
+		p.finish(ws, crashContext)
+	}()
+	return <-started
+}
+
+// trace handles the stops of all threads until the process exits. The context of the first fatal
+// signal is kept, since the handlers of the sanitizers and runtimes raise other signals afterwards.
+func (p *supervisedProcess) trace(pid int) (syscall.WaitStatus, *output.CrashContext) {
+	var crashContext *output.CrashContext
+	threads := map[int]bool{pid: true}
+	stopped := map[int]bool{}
+	var pending *hangRequest
+
+	for {
+		var ws syscall.WaitStatus
+		tid, err := syscall.Wait4(-pid, &ws, syscall.WALL, nil)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			return syscall.WaitStatus(0xff << 8), crashContext
+		}
+
+		if ws.Exited() || ws.Signaled() {
+			if tid == pid {
+				return ws, crashContext
+			}
+			delete(threads, tid)
+			delete(stopped, tid)
+			continue
+		}
+		if !ws.Stopped() {
+			continue
+		}
+
+		sig := ws.StopSignal()
+		switch {
+		case sig == syscall.SIGTRAP && ws.TrapCause() == syscall.PTRACE_EVENT_CLONE:
+			if newTid, err := syscall.PtraceGetEventMsg(tid); err == nil {
+				threads[int(newTid)] = true
+			}
+			sig = 0
+		case sig == syscall.SIGTRAP && ws.TrapCause() == syscall.PTRACE_EVENT_EXEC:
+			// Without the exec event the target gets the plain SIGTRAP, which would look like a crash
+			sig = 0
+		case sig == syscall.SIGSTOP && !threads[tid]:
+			// New threads start with SIGSTOP, which is not meant for the target
+			threads[tid] = true
+			sig = 0
+		case sig == syscall.SIGSTOP && isGroupStop(tid):
+			// Process is stopped from outside (e.g. for the snapshot), so its threads are kept stopped
+			// instead of being continued by the tracer
+			stopped[tid] = true
+			if len(stopped) >= len(threads) {
+				waitForContinue(pid)
+				for stoppedTid := range stopped {
+					syscall.PtraceCont(stoppedTid, 0)
+				}
+				stopped = map[int]bool{}
+			}
+			continue
+		case sig == syscall.SIGSTOP:
+			if request := takeHangRequest(p.hangRequests, &pending, tid); request != nil {
+				// Thread was stopped only for its context, so the signal is not delivered
+				request.reply <- captureContext(pid, tid, 0)
+				sig = 0
+			}
+		case fatalSignals[sig] && crashContext == nil:
+			crashContext = captureContext(pid, tid, sig)
+		}
+		syscall.PtraceCont(tid, int(sig))
+	}
+}
+
+// takeHangRequest returns the pending request for the thread. Expired requests are dropped, so a later SIGSTOP
+// (e.g. of the snapshot) is delivered as usual.
+func takeHangRequest(requests chan hangRequest, pending **hangRequest, tid int) *hangRequest {
+	select {
+	case request := <-requests:
+		*pending = &request
+	default:
+	}
+	request := *pending
+	if request == nil || time.Now().After(request.expires) {
+		*pending = nil
+		return nil
+	}
+	if request.tid != tid {
+		return nil
+	}
+	*pending = nil
+	return request
+}
+
+// captureHang stops the busy thread of the hanging process for a moment and returns its context
+func (p *supervisedProcess) captureHang() *output.CrashContext {
+	pid := p.cmd.Process.Pid
+	tid := busyThread(pid)
+	if tid == 0 {
+		return nil
+	}
+
+	request := hangRequest{
+		tid:     tid,
+		expires: time.Now().Add(hangContextTimeout),
+		reply:   make(chan *output.CrashContext, 1),
+	}
+	select {
+	case p.hangRequests <- request:
+	default:
+		return nil
+	}
+	if err := syscall.Tgkill(pid, tid, syscall.SIGSTOP); err != nil {
+		return nil
+	}
+
+	select {
+	case hangContext := <-request.reply:
+		return hangContext
+	case <-p.exited:
+	case <-time.After(hangContextTimeout):
+	}
+	return nil
+}
+
+// busyThread returns the first running thread of the process, which is most likely the one that hangs,
+// or 0 if all of them are waiting
+func busyThread(pid int) int {
+	entries, err := ioutil.ReadDir(fmt.Sprintf("/proc/%d/task", pid))
+	if err != nil {
+		return 0
+	}
+	for _, entry := range entries {
+		tid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/task/%d/stat", pid, tid))
+		if err != nil {
+			continue
+		}
+		// State follows the command name, which can contain spaces and parentheses
+		idx := strings.LastIndexByte(string(stat), ')')
+		if fields := strings.Fields(string(stat[idx+1:])); len(fields) > 0 && fields[0] == "R" {
+			return tid
+		}
+	}
+	return 0
+}
+
+// isGroupStop tells the group-stop from the signal delivery, since the signal information is only
+// available for the latter
+func isGroupStop(tid int) bool {
+	info := make([]byte, sigInfoSize)
+	_, _, errno := syscall.Syscall6(syscall.SYS_PTRACE, ptraceGetSigInfo, uintptr(tid), 0, uintptr(unsafe.Pointer(&info[0])), 0, 0)
+	return errno == syscall.EINVAL
+}
+
+// waitForContinue waits until the process held in the group-stop gets SIGCONT or SIGKILL. Tracer is not
+// notified about the signals sent to the stopped process, so its pending signals are polled.
+func waitForContinue(pid int) {
+	release := uint64(1)<<(syscall.SIGCONT-1) | uint64(1)<<(syscall.SIGKILL-1)
+	for {
+		pending, alive := sharedPendingSignals(pid)
+		if !alive || pending&release != 0 {
+			return
+		}
+		time.Sleep(stopPollInterval)
+	}
+}
+
+// sharedPendingSignals returns the signals sent to the whole process which are not delivered yet
+func sharedPendingSignals(pid int) (uint64, bool) {
+	status, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0, false
+	}
+	var pending uint64
+	for _, line := range strings.Split(string(status), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "State:":
+			if fields[1] == "Z" || fields[1] == "X" {
+				return 0, false
+			}
+		case "ShdPnd:":
+			pending, _ = strconv.ParseUint(fields[1], 16, 64)
+		}
+	}
+	return pending, true
+}
+
+// captureContext reads the registers, the signal information and the stack of the stopped thread
+func captureContext(pid, tid int, sig syscall.Signal) *output.CrashContext {
+	crashContext := &output.CrashContext{
+		ThreadID: tid,
+		Signal:   int(sig),
+	}
+
+	var regs syscall.PtraceRegs
+	if err := syscall.PtraceGetRegs(tid, &regs); err != nil {
+		return crashContext
+	}
+	crashContext.Registers = registerMap(&regs)
+
+	// Signal information is only about the crash, the thread of the hang has none
+	if fatalSignals[sig] {
+		info := make([]byte, sigInfoSize)
+		if _, _, errno := syscall.Syscall6(syscall.SYS_PTRACE, ptraceGetSigInfo, uintptr(tid), 0, uintptr(unsafe.Pointer(&info[0])), 0, 0); errno == 0 {
+			crashContext.SignalCode = int(int32(binary.LittleEndian.Uint32(info[sigInfoCodeOffset:])))
+			if sig != syscall.SIGABRT && sig != syscall.SIGTRAP {
+				crashContext.FaultAddress = fmt.Sprintf("0x%x", binary.LittleEndian.Uint64(info[sigInfoAddrOffset:]))
+			}
+		}
+	}
+
+	pc := regs.PC()
+	code := make([]byte, instructionByteCount)
+	if n, err := syscall.PtracePeekData(tid, uintptr(pc), code); err == nil {
+		crashContext.InstructionBytes = hex.EncodeToString(code[:n])
+	}
+
+	regions, _ := memdump.ReadMemoryMap(pid)
+	for _, region := range regions {
+		crashContext.MemoryMap = append(crashContext.MemoryMap,
+			fmt.Sprintf("%x-%x %s %08x %s", region.Start, region.End, region.Perms, region.Offset, region.Path))
+	}
+	crashContext.Backtrace = walkFramePointers(tid, pc, framePointer(&regs), regions)
+	return crashContext
+}
+
+// walkFramePointers unwinds the stack with the frame pointers. Code built without them gives
+// only the faulting frame.
+func walkFramePointers(tid int, pc, fp uint64, regions []memdump.MemoryRegion) []output.StackFrame {
+	frames := []output.StackFrame{newContextFrame(0, pc, regions)}
+	word := make([]byte, 8)
+	for len(frames) < maxBacktraceFrames && fp != 0 {
+		// Frame record is the previous frame pointer followed by the return address
+		if _, err := syscall.PtracePeekData(tid, uintptr(fp+8), word); err != nil {
+			break
+		}
+		ret := binary.LittleEndian.Uint64(word)
+		if _, err := syscall.PtracePeekData(tid, uintptr(fp), word); err != nil {
+			break
+		}
+		next := binary.LittleEndian.Uint64(word)
+		if ret == 0 {
+			break
+		}
+		frames = append(frames, newContextFrame(len(frames), ret, regions))
+		// Stack grows down, so the frame pointer which does not increase is corrupted
+		if next <= fp {
+			break
+		}
+		fp = next
+	}
+	return frames
+}
+
+func newContextFrame(index int, pc uint64, regions []memdump.MemoryRegion) output.StackFrame {
+	frame := output.StackFrame{
+		Index:   index,
+		Address: fmt.Sprintf("0x%x", pc),
+	}
+	for _, region := range regions {
+		if pc >= region.Start && pc < region.End && len(region.Path) > 0 {
+			frame.Module = region.Path
+			frame.Offset = fmt.Sprintf("0x%x", pc-moduleBase(region.Path, regions))
+			break
+		}
+	}
+	return frame
+}
+
+// moduleBase is the lowest mapping of the module, so the offsets match the ones printed by the sanitizers
+func moduleBase(path string, regions []memdump.MemoryRegion) uint64 {
+	base := ^uint64(0)
+	for _, region := range regions {
+		if region.Path == path && region.Start < base {
+			base = region.Start
+		}
+	}
+	return base
+}
@@ -0,0 +1,28 @@
+//go:build linux && amd64
+// +build linux,amd64
+
+package watcher
+
+import (
+	"fmt"
+	"syscall"
+)
+
+func framePointer(regs *syscall.PtraceRegs) uint64 {
+	return regs.Rbp
+}
+
+func registerMap(regs *syscall.PtraceRegs) map[string]string {
+	values := map[string]uint64{
+		"rax": regs.Rax, "rbx": regs.Rbx, "rcx": regs.Rcx, "rdx": regs.Rdx,
+		"rsi": regs.Rsi, "rdi": regs.Rdi, "rbp": regs.Rbp, "rsp": regs.Rsp,
+		"r8": regs.R8, "r9": regs.R9, "r10": regs.R10, "r11": regs.R11,
+		"r12": regs.R12, "r13": regs.R13, "r14": regs.R14, "r15": regs.R15,
+		"rip": regs.Rip, "eflags": regs.Eflags,
+	}
+	registers := make(map[string]string, len(values))
+	for name, value := range values {
+		registers[name] = fmt.Sprintf("0x%016x", value)
+	}
+	return registers
+}
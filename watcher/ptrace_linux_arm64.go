@@ -0,0 +1,24 @@
+//go:build linux && arm64
+// +build linux,arm64
+
+package watcher
+
+import (
+	"fmt"
+	"syscall"
+)
+
+func framePointer(regs *syscall.PtraceRegs) uint64 {
+	return regs.Regs[29]
+}
+
+func registerMap(regs *syscall.PtraceRegs) map[string]string {
+	registers := make(map[string]string, len(regs.Regs)+3)
+	for i, value := range regs.Regs {
+		registers[fmt.Sprintf("x%d", i)] = fmt.Sprintf("0x%016x", value)
+	}
+	registers["sp"] = fmt.Sprintf("0x%016x", regs.Sp)
+	registers["pc"] = fmt.Sprintf("0x%016x", regs.Pc)
+	registers["pstate"] = fmt.Sprintf("0x%016x", regs.Pstate)
+	return registers
+}
@@ -0,0 +1,19 @@
+//go:build linux && !amd64 && !arm64
+// +build linux,!amd64,!arm64
+
+package watcher
+
+import (
+	"runtime"
+
+	"github.com/lukjok/gipcfuzz/output"
+	"github.com/pkg/errors"
+)
+
+func (p *supervisedProcess) startTraced() error {
+	return errors.Errorf("Ptrace supervision is not supported on %s", runtime.GOARCH)
+}
+
+func (p *supervisedProcess) captureHang() *output.CrashContext {
+	return nil
+}
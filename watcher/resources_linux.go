@@ -0,0 +1,50 @@
+//go:build linux
+// +build linux
+
+package watcher
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Clock ticks of the CPU times in /proc/<pid>/stat, it is 100 on all supported architectures
+const clockTicksPerSecond = 100
+
+// SampleResources reads the resident memory, CPU time, thread count and open descriptor count from /proc/<pid>
+func SampleResources(pid int) (*ResourceUsage, error) {
+	statPath := fmt.Sprintf("/proc/%d/stat", pid)
+	data, err := ioutil.ReadFile(statPath)
+	if err != nil {
+		return nil, errors.Errorf("Failed to sample resources of the process %d: %s", pid, err)
+	}
+
+	// Fields after the name in the parentheses, starting from the state
+	stat := string(data)
+	fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+	if len(fields) < 22 {
+		return nil, errors.Errorf("Unexpected format of %s", statPath)
+	}
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	threads, _ := strconv.Atoi(fields[17])
+	rssPages, _ := strconv.ParseUint(fields[21], 10, 64)
+
+	fds, err := ioutil.ReadDir(fmt.Sprintf("/proc/%d/fd", pid))
+	if err != nil {
+		return nil, errors.Errorf("Failed to list descriptors of the process %d: %s", pid, err)
+	}
+
+	return &ResourceUsage{
+		RSS:       rssPages * uint64(os.Getpagesize()),
+		CPUTime:   time.Duration(utime+stime) * time.Second / clockTicksPerSecond,
+		Threads:   threads,
+		OpenFiles: len(fds),
+	}, nil
+}
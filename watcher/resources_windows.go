@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package watcher
+
+import "github.com/pkg/errors"
+
+// SampleResources is not supported on Windows, since there is no /proc
+func SampleResources(pid int) (*ResourceUsage, error) {
+	return nil, errors.New("Resource sampling is not supported on Windows!")
+}
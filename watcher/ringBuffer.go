@@ -0,0 +1,58 @@
+package watcher
+
+import "sync"
+
+// Only the tail of the process output is needed to explain the crash
+const defaultOutputBufferSize = 64 * 1024
+
+// RingBuffer keeps the last written bytes up to its size. It is used to capture
+// the output of the long running target without growing the memory usage.
+type RingBuffer struct {
+	mu   sync.Mutex
+	data []byte
+	size int
+	pos  int
+	full bool
+}
+
+func NewRingBuffer(size int) *RingBuffer {
+	return &RingBuffer{
+		data: make([]byte, size),
+		size: size,
+	}
+}
+
+func (r *RingBuffer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := len(p)
+	if n >= r.size {
+		copy(r.data, p[n-r.size:])
+		r.pos = 0
+		r.full = true
+		return n, nil
+	}
+
+	written := copy(r.data[r.pos:], p)
+	if written < n {
+		copy(r.data, p[written:])
+		r.full = true
+	}
+	r.pos = (r.pos + n) % r.size
+	if r.pos == 0 && n > 0 {
+		r.full = true
+	}
+	return n, nil
+}
+
+// String returns the buffered output in the order it was written
+func (r *RingBuffer) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		return string(r.data[:r.pos])
+	}
+	return string(r.data[r.pos:]) + string(r.data[:r.pos])
+}
@@ -0,0 +1,80 @@
+//go:build linux
+// +build linux
+
+package watcher
+
+import (
+	"os/exec"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"github.com/lukjok/gipcfuzz/config"
+	"github.com/pkg/errors"
+)
+
+// startLimited starts the process stopped right after the exec, applies the limits and only then lets it run,
+// so the target never runs without them. Go cannot set the limits between the fork and the exec.
+func startLimited(cmd *exec.Cmd, limits config.ResourceLimits) error {
+	if limits == (config.ResourceLimits{}) {
+		return cmd.Start()
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Ptrace = true
+
+	// Every ptrace request has to come from the thread which has started the process
+	started := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		if err := cmd.Start(); err != nil {
+			started <- err
+			return
+		}
+		pid := cmd.Process.Pid
+		var ws syscall.WaitStatus
+		if _, err := syscall.Wait4(pid, &ws, syscall.WALL, nil); err != nil || !ws.Stopped() {
+			cmd.Process.Kill()
+			cmd.Wait()
+			started <- errors.Errorf("Failed to stop the process %d at the exec: %v", pid, err)
+			return
+		}
+		if err := setResourceLimits(pid, limits); err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			started <- err
+			return
+		}
+		started <- syscall.PtraceDetach(pid)
+	}()
+	return <-started
+}
+
+// setResourceLimits applies the limits to the process stopped at the exec with prlimit
+func setResourceLimits(pid int, limits config.ResourceLimits) error {
+	resources := []struct {
+		resource int
+		value    uint64
+		name     string
+	}{
+		{syscall.RLIMIT_AS, limits.AddressSpace, "address space"},
+		{syscall.RLIMIT_CPU, limits.CPUTime, "CPU time"},
+		{syscall.RLIMIT_NOFILE, limits.OpenFiles, "open files"},
+	}
+
+	for _, res := range resources {
+		if res.value == 0 {
+			continue
+		}
+		limit := syscall.Rlimit{Cur: res.value, Max: res.value}
+		_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(res.resource),
+			uintptr(unsafe.Pointer(&limit)), 0, 0, 0)
+		if errno != 0 {
+			return errors.Errorf("Failed to set the %s limit of the process %d: %s", res.name, pid, errno)
+		}
+	}
+	return nil
+}
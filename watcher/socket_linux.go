@@ -0,0 +1,131 @@
+//go:build linux
+// +build linux
+
+package watcher
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TCP state of the listening sockets in /proc/net/tcp
+const tcpListenState = "0A"
+
+// findSocketOwner returns the PID of the process listening on the socket. The socket is either the TCP
+// address ("127.0.0.1:50051" or ":50051") or the path of the unix socket ("unix:/tmp/target.sock").
+func findSocketOwner(socket string) (int, error) {
+	var inodes map[string]bool
+	var err error
+	if path := strings.TrimPrefix(socket, "unix:"); path != socket || strings.HasPrefix(socket, "/") {
+		inodes, err = unixSocketInodes(path)
+	} else {
+		inodes, err = tcpSocketInodes(socket)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(inodes) == 0 {
+		return 0, errors.Errorf("Nothing is listening on %s", socket)
+	}
+
+	procList, err := processes()
+	if err != nil {
+		return 0, err
+	}
+	// Workers forked by the server share its socket, so the oldest process is taken
+	owner := 0
+	for _, proc := range procList {
+		if (owner == 0 || proc.Pid() < owner) && ownsSocket(proc.Pid(), inodes) {
+			owner = proc.Pid()
+		}
+	}
+	if owner == 0 {
+		return 0, errors.Errorf("Owner of the socket %s was not found", socket)
+	}
+	return owner, nil
+}
+
+func tcpSocketInodes(address string) (map[string]bool, error) {
+	idx := strings.LastIndex(address, ":")
+	port, err := strconv.ParseUint(address[idx+1:], 10, 16)
+	if err != nil {
+		return nil, errors.Errorf("Invalid socket address %s", address)
+	}
+
+	inodes := make(map[string]bool)
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		rows, err := readProcNetTable(table)
+		if err != nil {
+			continue
+		}
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+		for _, fields := range rows {
+			if len(fields) < 10 || fields[3] != tcpListenState {
+				continue
+			}
+			local := fields[1]
+			localPort, err := strconv.ParseUint(local[strings.LastIndex(local, ":")+1:], 16, 16)
+			if err == nil && localPort == port {
+				inodes[fields[9]] = true
+			}
+		}
+	}
+	return inodes, nil
+}
+
+func unixSocketInodes(path string) (map[string]bool, error) {
+	rows, err := readProcNetTable("/proc/net/unix")
+	if err != nil {
+		return nil, err
+	}
+	// Num RefCount Protocol Flags Type St Inode Path
+	inodes := make(map[string]bool)
+	for _, fields := range rows {
+		if len(fields) >= 8 && fields[7] == path {
+			inodes[fields[6]] = true
+		}
+	}
+	return inodes, nil
+}
+
+func readProcNetTable(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rows := make([][]string, 0, 16)
+	scanner := bufio.NewScanner(f)
+	for first := true; scanner.Scan(); first = false {
+		if !first {
+			rows = append(rows, strings.Fields(scanner.Text()))
+		}
+	}
+	return rows, scanner.Err()
+}
+
+func ownsSocket(pid int, inodes map[string]bool) bool {
+	fdDir := fmt.Sprintf("/proc/%d/fd", pid)
+	fds, err := ioutil.ReadDir(fdDir)
+	if err != nil {
+		return false
+	}
+	for _, fd := range fds {
+		link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+		if err != nil || !strings.HasPrefix(link, "socket:[") {
+			continue
+		}
+		if inodes[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+//go:build windows
+// +build windows
+
+package watcher
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// findSocketOwner returns the PID of the process listening on the TCP address ("127.0.0.1:50051" or ":50051").
+// Listening sockets are taken from netstat, since it is available on every Windows version.
+func findSocketOwner(socket string) (int, error) {
+	if strings.HasPrefix(socket, "unix:") {
+		return 0, errors.New("Unix sockets are not supported on Windows!")
+	}
+	port := socket[strings.LastIndex(socket, ":")+1:]
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return 0, errors.Errorf("Invalid socket address %s", socket)
+	}
+
+	for _, proto := range []string{"TCP", "TCPv6"} {
+		out, err := exec.Command("C:\\Windows\\System32\\netstat.exe", "-ano", "-p", proto).Output()
+		if err != nil {
+			return 0, errors.Errorf("Failed to list the sockets: %s", err)
+		}
+		// Proto Local Address Foreign Address State PID
+		for _, line := range strings.Split(string(out), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 5 || fields[3] != "LISTENING" || !strings.HasSuffix(fields[1], ":"+port) {
+				continue
+			}
+			if pid, err := strconv.Atoi(fields[4]); err == nil {
+				return pid, nil
+			}
+		}
+	}
+	return 0, errors.Errorf("Nothing is listening on %s", socket)
+}